@@ -1,34 +1,384 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yankokirill/song-library/config"
+	"github.com/yankokirill/song-library/internal/auth"
 	"github.com/yankokirill/song-library/internal/delivery/http"
+	"github.com/yankokirill/song-library/internal/events"
+	"github.com/yankokirill/song-library/internal/health"
+	"github.com/yankokirill/song-library/internal/jobs"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/metrics"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/ratelimit"
+	"github.com/yankokirill/song-library/internal/repository/cache"
 	"github.com/yankokirill/song-library/internal/repository/migrations"
 	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"github.com/yankokirill/song-library/internal/resync"
 	"github.com/yankokirill/song-library/internal/rpc"
-	"log"
+	"github.com/yankokirill/song-library/internal/service"
+	"github.com/yankokirill/song-library/internal/tracing"
+	"github.com/yankokirill/song-library/internal/webhooks"
+	"log/slog"
+	stdhttp "net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 // @title Song Library API
 // @version 1.0
 // @description This is the API documentation for managing songs in a library.
+// @description Errors are reported as RFC 7807 problem details (application/problem+json).
+// @description Invalid parameters are listed one by one in "errors", and every problem carries
+// @description the id of the request, which is also sent in the X-Request-Id header.
 // @host localhost:8080
 // @BasePath /library
 // @schemes http
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key with the read, write or admin scope, issued through /admin/keys.
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT as "Bearer <token>", whose roles claim grants the read, write or admin scope.
 func main() {
 	config.Load()
 
-	if err := migrations.Up("file://migrations", config.DatabaseURL()); err != nil {
-		log.Fatalf("%v", err)
+	logger, err := logging.New(os.Stderr, config.LogLevel(), config.LogFormat())
+	if err != nil {
+		fatal("invalid logging settings", logging.Err(err))
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), config.TraceExporter())
+	if err != nil {
+		fatal("failed to set up tracing", logging.Err(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush spans", logging.Err(err))
+		}
+	}()
+
+	if err = migrations.Up("file://migrations", config.DatabaseURL()); err != nil {
+		fatal("failed to apply database migrations", logging.Err(err))
 	}
-	repo, err := postgres.NewSongRepository(config.DatabaseURL())
+	db, err := postgres.Connect(config.DatabaseURL())
 	if err != nil {
-		log.Fatalf("failed to start postgresql database: %v", err)
+		fatal("failed to start postgresql database", logging.Err(err))
+	}
+	defer db.Close()
+	pools := map[string]metrics.PoolStater{"primary": db}
+
+	repo, err := postgres.NewSongRepository(db,
+		postgres.WithReplicas(config.ReplicaURLs()...),
+		postgres.WithReadYourWritesWindow(config.ReadYourWritesWindow()),
+	)
+	if err != nil {
+		fatal("failed to connect to the read replicas", logging.Err(err))
 	}
 	defer repo.Close()
 
-	rpc.SetExternalApiURL(config.ExternalApiURL())
-	server := http.NewServer(repo, config.ServerAddress())
+	syncRepo := postgres.NewSyncRepository(db)
+	var serverOpts []http.ServerOption
+	if config.CacheEnabled() {
+		cached := cache.NewSongRepository(repo, config.CacheSize(), config.CacheTTL())
+		serverOpts = append(serverOpts, http.WithCacheStats(cached))
+		repo = cached
+		syncRepo = cache.NewSyncRepository(syncRepo, cached)
+	}
+
+	outbox := postgres.NewOutboxRepository(db)
+	webhookRepo := postgres.NewWebhookRepository(db)
+	changeLog := postgres.NewChangeLogRepository(db)
+	jobRepo := postgres.NewJobRepository(db)
+
+	ctx, stop := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	defer stop()
+	runWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(ctx)
+		}()
+	}
+
+	dispatcher := events.NewDispatcher(outbox, config.OutboxPollInterval(),
+		events.LogSink{},
+		webhooks.NewSink(webhookRepo),
+	)
+	runWorker(dispatcher.Run)
+
+	deliverer := webhooks.NewDeliverer(webhookRepo,
+		webhooks.WithMaxAttempts(config.WebhookMaxAttempts()),
+		webhooks.WithBackoff(config.WebhookBackoff(), config.WebhookMaxBackoff()),
+	)
+	runWorker(deliverer.Run)
+
+	feed := events.NewFeed(changeLog, config.ChangeLogRetention())
+	runWorker(feed.Run)
+
+	dateFormat, err := models.ParseDateFormat(config.DateFormat())
+	if err != nil {
+		fatal("invalid DATE_FORMAT", logging.Err(err))
+	}
+
+	var appMetrics *metrics.Metrics
+	var observer rpc.CallObserver
+	if config.MetricsEnabled() {
+		appMetrics = metrics.New()
+		observer = appMetrics
+	}
+
+	breaker := rpc.NewCircuitBreaker(config.BreakerThreshold(), config.BreakerCooldown())
+	details, err := newSongDetailProvider(config.DetailProviders(), breaker, observer)
+	if err != nil {
+		fatal("failed to set up song detail providers", logging.Err(err))
+	}
+
+	// Additions may use cached lookups; syncs always ask the providers,
+	// as they are looking for corrections made upstream.
+	lookups := details
+	if config.LookupCacheEnabled() {
+		lookupCache := postgres.NewLookupCacheRepository(db)
+		lookups = rpc.NewCachingProvider(details, lookupCache,
+			config.LookupCacheTTL(), config.LookupCacheNotFoundTTL())
+		serverOpts = append(serverOpts, http.WithLookupCache(lookupCache))
+	}
+
+	songs := service.NewSongService(repo, lookups)
+	pool := jobs.NewPool(jobRepo, songs.AddSongJob,
+		jobs.WithWorkers(config.JobWorkers()),
+		jobs.WithPollInterval(config.JobPollInterval()),
+		jobs.WithLease(config.JobLease()),
+		jobs.WithMaxAttempts(config.JobMaxAttempts()),
+	)
+	runWorker(pool.Run)
+
+	for _, field := range config.SyncAutoApply() {
+		switch field {
+		case models.SyncFieldReleaseDate, models.SyncFieldLink, models.SyncFieldLyrics:
+		default:
+			fatal("invalid SYNC_AUTO_APPLY field", "field", field)
+		}
+	}
+	syncer := resync.NewSyncer(syncRepo, details,
+		resync.WithInterval(config.SyncInterval()),
+		resync.WithMaxAge(config.SyncMaxAge()),
+		resync.WithBatchSize(config.SyncBatchSize()),
+		resync.WithAutoApply(config.SyncAutoApply()...),
+	)
+	runWorker(syncer.Run)
+
+	serverOpts = append(serverOpts,
+		http.WithDateFormat(dateFormat),
+		http.WithJobs(jobRepo),
+		http.WithSync(syncer, syncRepo),
+		http.WithWebhooks(webhookRepo),
+		http.WithEventFeed(feed),
+		http.WithBreakerStatus(breaker),
+	)
+	if config.AuthEnabled() {
+		keyRepo := postgres.NewAPIKeyRepository(db)
+		serverOpts = append(serverOpts, http.WithAPIKeys(keyRepo, config.BootstrapAPIKey()))
+
+		if config.JWTSecret() != "" || config.JWTKeySetFile() != "" {
+			verifier, err := newJWTVerifier()
+			if err != nil {
+				fatal("failed to set up jwt authentication", logging.Err(err))
+			}
+			serverOpts = append(serverOpts, http.WithJWT(verifier))
+		}
+	} else {
+		slog.Warn("authentication is disabled, anyone may change the library")
+	}
+
+	if config.RateLimitEnabled() {
+		store, err := newRateLimitStore(config.RateLimitStore(), db)
+		if err != nil {
+			fatal("failed to set up rate limiting", logging.Err(err))
+		}
+
+		readRate, readBurst := config.RateLimitRead()
+		writeRate, writeBurst := config.RateLimitWrite()
+		readLimit := ratelimit.Limit{Rate: readRate, Burst: readBurst}
+		if err := readLimit.Validate(); err != nil {
+			fatal("invalid RATE_LIMIT_READ_RATE or RATE_LIMIT_READ_BURST", logging.Err(err))
+		}
+		writeLimit := ratelimit.Limit{Rate: writeRate, Burst: writeBurst}
+		if err := writeLimit.Validate(); err != nil {
+			fatal("invalid RATE_LIMIT_WRITE_RATE or RATE_LIMIT_WRITE_BURST", logging.Err(err))
+		}
+		serverOpts = append(serverOpts, http.WithRateLimit(store, readLimit, writeLimit))
+	}
+
+	checker, err := newHealthChecker(repo)
+	if err != nil {
+		fatal("failed to set up readiness checks", logging.Err(err))
+	}
+	serverOpts = append(serverOpts,
+		http.WithHealth(checker),
+		http.WithDrainDelay(config.ShutdownDrainDelay()),
+	)
+
+	if appMetrics != nil {
+		appMetrics.RegisterPools(pools)
+		appMetrics.RegisterSongCounter(repo)
+		serverOpts = append(serverOpts, http.WithMetrics(appMetrics))
+	}
+
+	server := http.NewServer(repo, lookups, config.ServerAddress(), serverOpts...)
 	go server.Run()
 	server.Shutdown()
 }
+
+// fatal logs the error that keeps the service from starting and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// newSongDetailProvider chains the named providers in the given order.
+func newSongDetailProvider(names []string, breaker *rpc.CircuitBreaker, observer rpc.CallObserver) (rpc.SongDetailProvider, error) {
+	transport, err := newExternalApiTransport(config.ExternalApiMode(), config.ExternalApiFixtures())
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []rpc.SongDetailProvider
+	for _, name := range names {
+		switch name {
+		case "external-api":
+			opts := []rpc.HTTPProviderOption{
+				rpc.WithTimeout(config.ExternalApiTimeout()),
+				rpc.WithMaxAttempts(config.ExternalApiMaxAttempts()),
+				rpc.WithBackoff(config.ExternalApiBackoff(), config.ExternalApiMaxBackoff()),
+				rpc.WithCircuitBreaker(breaker),
+				rpc.WithMaxConcurrency(config.ExternalApiMaxConcurrency()),
+			}
+			if rate := config.ExternalApiRate(); rate > 0 {
+				bucket := ratelimit.NewBucket(rate, config.ExternalApiBurst())
+				opts = append(opts, rpc.WithRateLimit(bucket, config.ExternalApiMaxRateWait()))
+			}
+			if observer != nil {
+				opts = append(opts, rpc.WithCallObserver(observer))
+			}
+			if transport != nil {
+				opts = append(opts, rpc.WithTransport(transport))
+			}
+			providers = append(providers, rpc.NewHTTPProvider(config.ExternalApiURL(), opts...))
+		case "file":
+			provider, err := rpc.NewFileProvider(config.SongCataloguePath())
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		default:
+			return nil, fmt.Errorf("unknown song detail provider %q", name)
+		}
+	}
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	return rpc.NewChainProvider(providers...), nil
+}
+
+// newRateLimitStore returns the named store of the rate limit buckets.
+func newRateLimitStore(name string, db *pgxpool.Pool) (ratelimit.Store, error) {
+	switch name {
+	case "memory":
+		return ratelimit.NewMemoryStore(), nil
+	case "postgres":
+		return postgres.NewRateLimitRepository(db), nil
+	}
+	return nil, fmt.Errorf("unknown rate limit store %q", name)
+}
+
+func newJWTVerifier() (*auth.JWTVerifier, error) {
+	opts := []auth.JWTOption{
+		auth.WithIssuer(config.JWTIssuer()),
+		auth.WithAudience(config.JWTAudience()),
+		auth.WithRolesClaim(config.JWTRolesClaim()),
+	}
+	if secret := config.JWTSecret(); secret != "" {
+		opts = append(opts, auth.WithHMACSecret([]byte(secret)))
+	}
+	if path := config.JWTKeySetFile(); path != "" {
+		opts = append(opts, auth.WithJWKSFile(path))
+	}
+
+	mapping := make(map[string]string)
+	for _, pair := range config.JWTRoleMapping() {
+		claimed, role, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid AUTH_JWT_ROLE_MAPPING entry %q, expected <claimed role>=<scope>", pair)
+		}
+		mapping[strings.TrimSpace(claimed)] = strings.TrimSpace(role)
+	}
+	opts = append(opts, auth.WithRoleMapping(mapping))
+	return auth.NewJWTVerifier(opts...)
+}
+
+// newHealthChecker checks that the database is reachable and migrated,
+// and that the external API answers if so configured.
+func newHealthChecker(repo postgres.SongRepository) (*health.Checker, error) {
+	expected, err := migrations.Latest("file://migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []health.Check{
+		{Name: "database", Run: repo.Ping},
+		{Name: "migrations", Run: func(ctx context.Context) error {
+			version, dirty, err := repo.SchemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if dirty {
+				return fmt.Errorf("migration %d failed halfway", version)
+			}
+			if version != expected {
+				return fmt.Errorf("database is at version %d, expected %d", version, expected)
+			}
+			return nil
+		}},
+	}
+	if config.HealthCheckExternalApi() {
+		client := &stdhttp.Client{Timeout: config.HealthCheckTimeout()}
+		checks = append(checks, health.Check{Name: "external_api", Run: health.Reachable(client, config.ExternalApiURL())})
+	}
+	return health.NewChecker(checks,
+		health.WithCacheTTL(config.HealthCacheTTL()),
+		health.WithTimeout(config.HealthCheckTimeout()),
+	), nil
+}
+
+// newExternalApiTransport returns the transport for the external API mode,
+// or nil to use the default one.
+func newExternalApiTransport(mode, fixtures string) (stdhttp.RoundTripper, error) {
+	switch mode {
+	case "live":
+		return nil, nil
+	case "record", "replay":
+	default:
+		return nil, fmt.Errorf("unknown external api mode %q", mode)
+	}
+
+	if fixtures == "" {
+		return nil, fmt.Errorf("EXTERNAL_API_FIXTURES must be set in %s mode", mode)
+	}
+	if mode == "record" {
+		return rpc.NewRecordingTransport(stdhttp.DefaultTransport, fixtures)
+	}
+	return rpc.NewReplayTransport(fixtures)
+}
@@ -0,0 +1,52 @@
+// Command mockapi serves an imitation of the external music info API, with
+// faults that can be injected at start-up through flags and changed while it
+// runs through /faults:
+//
+//	curl -X PUT localhost:8081/faults -d '{"latencyMs": 200, "errorRate": 0.1, "errorStatus": 503,
+//	  "songs": [{"title": "Yellow", "group": "Coldplay", "malformed": true}]}'
+package main
+
+import (
+	"flag"
+	"github.com/yankokirill/song-library/internal/mockapi"
+	"log"
+	"net/http"
+	"time"
+)
+
+func main() {
+	address := flag.String("addr", ":8081", "address to listen on")
+	dataPath := flag.String("data", "", "JSON file of songs to serve; the test songs by default")
+	latency := flag.Duration("latency", 0, "delay of every response")
+	errorRate := flag.Float64("error-rate", 0, "share of requests, from 0 to 1, to fail")
+	errorStatus := flag.Int("error-status", http.StatusInternalServerError, "status code of the failed requests")
+	retryAfter := flag.String("retry-after", "", "Retry-After header of the failed requests")
+	flag.Parse()
+
+	songs, err := mockapi.LoadSongs(mockapi.DefaultSongs)
+	if *dataPath != "" {
+		songs, err = mockapi.ReadSongs(*dataPath)
+	}
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	faults := &mockapi.Faults{}
+	err = faults.Apply(mockapi.Settings{
+		LatencyMS:   int(latency.Milliseconds()),
+		ErrorRate:   *errorRate,
+		ErrorStatus: *errorStatus,
+		RetryAfter:  *retryAfter,
+	})
+	if err != nil {
+		log.Fatalf("invalid faults: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:              *address,
+		Handler:           mockapi.NewHandler(songs, faults),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	log.Printf("mock external api with %d songs listening on %s", len(songs), *address)
+	log.Fatal(server.ListenAndServe())
+}
@@ -15,164 +15,242 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/song": {
-            "post": {
-                "description": "Add a new song to the library with the given title and group.",
-                "tags": [
-                    "API"
-                ],
-                "summary": "Add a new song",
-                "parameters": [
+        "/admin/breaker": {
+            "get": {
+                "security": [
                     {
-                        "description": "Title and group",
-                        "name": "song",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/http.SongAddRequest"
-                        }
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
+                "description": "Get the state of the circuit breaker guarding the external song API.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get external API circuit breaker state",
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "Circuit breaker state",
                         "schema": {
-                            "$ref": "#/definitions/http.SongAddResponse"
+                            "$ref": "#/definitions/rpc.BreakerStatus"
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "401": {
+                        "description": "Missing or invalid credentials",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Caller without the required scope",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             }
         },
-        "/song/{id}": {
+        "/admin/cache": {
             "get": {
-                "description": "Get song verses in partitions with pagination.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the hit and miss counters and the size of the song cache.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "API"
+                    "Admin"
                 ],
-                "summary": "Get the lyrics of a specific song",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "ID of the song",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                "summary": "Get song cache statistics",
+                "responses": {
+                    "200": {
+                        "description": "Cache statistics",
+                        "schema": {
+                            "$ref": "#/definitions/cache.Stats"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
                     },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys": {
+            "get": {
+                "security": [
                     {
-                        "type": "integer",
-                        "default": 0,
-                        "description": "Offset for starting from a specific verse",
-                        "name": "offset",
-                        "in": "query"
+                        "ApiKeyAuth": []
                     },
                     {
-                        "type": "integer",
-                        "default": 20,
-                        "description": "Maximum number of verses to retrieve",
-                        "name": "limit",
-                        "in": "query"
+                        "BearerAuth": []
                     }
                 ],
+                "description": "List the issued keys, revoked ones included, without the keys themselves.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List API keys",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/http.SongLyricsResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.APIKey"
+                            }
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "401": {
+                        "description": "Missing or invalid credentials",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Song not found",
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             },
-            "put": {
-                "description": "Update the details of an existing song, identified by its ID.",
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Issue a key with the given scopes: read, write (which includes read) or admin (which includes all).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "API"
+                    "Admin"
                 ],
-                "summary": "Update an existing song",
+                "summary": "Issue an API key",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "ID of the song to be updated",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Updated song details",
-                        "name": "song",
+                        "description": "Name and scopes of the key",
+                        "name": "key",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SongInfo"
+                            "$ref": "#/definitions/http.APIKeyCreateRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Updated song details",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.SongInfo"
+                            "$ref": "#/definitions/models.APIKey"
                         }
                     },
                     "400": {
                         "description": "Invalid request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Song not found",
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/admin/keys/{id}": {
             "delete": {
-                "description": "Delete a song from the library by its ID.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke a key; requests made with it are rejected from now on.",
                 "tags": [
-                    "API"
+                    "Admin"
                 ],
-                "summary": "Delete a song",
+                "summary": "Revoke an API key",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "ID of the song to be deleted",
+                        "description": "ID of the key",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -180,101 +258,196 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "204": {
-                        "description": "Song successfully deleted"
+                        "description": "Key successfully revoked"
                     },
                     "400": {
                         "description": "Invalid request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Key not found or already revoked",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             }
         },
-        "/songs": {
-            "get": {
-                "description": "Get song information in partitions using lexicographical order and pagination.",
+        "/admin/lookup-cache": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Forget the cached answers of the song detail providers, found or not,",
                 "tags": [
-                    "API"
+                    "Admin"
                 ],
-                "summary": "Get information about songs",
+                "summary": "Purge cached song detail lookups",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Title of the last song in the previous partition",
-                        "name": "prevSong",
+                        "description": "Title of the song",
+                        "name": "song",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Group of the last song in the previous partition",
-                        "name": "prevGroup",
+                        "description": "Group of the song",
+                        "name": "group",
                         "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of purged entries",
+                        "schema": {
+                            "$ref": "#/definitions/http.LookupCachePurgeResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
                     },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/sync": {
+            "post": {
+                "security": [
                     {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Maximum number of songs to retrieve",
-                        "name": "limit",
-                        "in": "query"
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
                     }
                 ],
+                "description": "Look up the details of the stale songs again right away instead of waiting for the schedule.",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Start a song sync run",
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "202": {
+                        "description": "Started run",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.SongInfo"
-                            }
+                            "$ref": "#/definitions/models.SyncRun"
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "A run is already in progress",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             }
         },
-        "/songs/{group}": {
+        "/admin/sync/diffs": {
             "get": {
-                "description": "Get song information for a specific musical group in partitions using lexicographical order and pagination.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the differences found by sync runs, e.g. those waiting for review.",
                 "tags": [
-                    "API"
+                    "Admin"
                 ],
-                "summary": "Get information about songs of a specific group",
+                "summary": "List song differences",
                 "parameters": [
                     {
+                        "enum": [
+                            "pending",
+                            "applied",
+                            "rejected"
+                        ],
                         "type": "string",
-                        "description": "Name of the group",
-                        "name": "group",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Title of the last song in the previous partition",
-                        "name": "prevSong",
+                        "description": "Filter by status",
+                        "name": "status",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "default": 10,
-                        "description": "Maximum number of songs to retrieve",
+                        "default": 50,
+                        "description": "Maximum number of differences, newest first",
                         "name": "limit",
                         "in": "query"
                     }
@@ -285,35 +458,1585 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.SongInfo"
+                                "$ref": "#/definitions/models.SongDiff"
                             }
                         }
                     },
                     "400": {
                         "description": "Invalid request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "http.SongAddRequest": {
-            "type": "object",
-            "properties": {
-                "group": {
-                    "type": "string"
-                },
-                "song": {
-                    "type": "string"
+        },
+        "/admin/sync/diffs/{id}/apply": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the song with the value found upstream.",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Apply a song difference",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the difference",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SongDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Difference not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Difference already resolved, or the song has changed since it was found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/sync/diffs/{id}/reject": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Keep the stored value of the song.",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reject a song difference",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the difference",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SongDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Difference not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Difference already resolved",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/sync/runs": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List song sync runs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Maximum number of runs, newest first",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SyncRun"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/sync/runs/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the report of a run with the differences it found.",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get a song sync run",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the run",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SyncRun"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Run not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stream song changes as Server-Sent Events. Every event has the change id as its id,",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "API"
+                ],
+                "summary": "Stream library changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the last received event",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last received event, if the header cannot be set",
+                        "name": "lastEventId",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "description": "Format of release dates in the events",
+                        "name": "dateFormat",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of events",
+                        "schema": {
+                            "$ref": "#/definitions/http.SongInfoResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the status of a song being added in the background:",
+                "tags": [
+                    "API"
+                ],
+                "summary": "Get a background job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the job",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/song": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a new song to the library with the given title and group.",
+                "tags": [
+                    "API"
+                ],
+                "summary": "Add a new song",
+                "parameters": [
+                    {
+                        "description": "Title and group, and the details of a manual song",
+                        "name": "song",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.SongAddRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Add the song in the background",
+                        "name": "async",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "manual"
+                        ],
+                        "type": "string",
+                        "description": "Where the song details come from",
+                        "name": "source",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/http.SongAddResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Job adding the song",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Song unknown to the providers",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "External API failed or returned invalid details",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "External API unavailable or rate limited, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "504": {
+                        "description": "External API timed out",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/song/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get song verses in partitions with pagination.",
+                "tags": [
+                    "API"
+                ],
+                "summary": "Get the lyrics of a specific song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Offset for starting from a specific verse",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Maximum number of verses to retrieve",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.SongLyricsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the details of an existing song, identified by its ID.",
+                "tags": [
+                    "API"
+                ],
+                "summary": "Update an existing song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song to be updated",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated song details",
+                        "name": "song",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SongInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated song details",
+                        "schema": {
+                            "$ref": "#/definitions/models.SongInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Song not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a song from the library by its ID.",
+                "tags": [
+                    "API"
+                ],
+                "summary": "Delete a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the song to be deleted",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Song successfully deleted"
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get song information in partitions using lexicographical order and pagination.",
+                "tags": [
+                    "API"
+                ],
+                "summary": "Get information about songs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Title of the last song in the previous partition",
+                        "name": "prevSong",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Group of the last song in the previous partition",
+                        "name": "prevGroup",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Maximum number of songs to retrieve",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only songs released in or after this period (e.g. 2006, 2006-07, 16.07.2006)",
+                        "name": "releasedFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only songs released in or before this period (e.g. 2006, 2006-07, 16.07.2006)",
+                        "name": "releasedTo",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "description": "Format of release dates in the response",
+                        "name": "dateFormat",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "description": "Format of release dates in the response, if 'dateFormat' is not set",
+                        "name": "X-Date-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.SongInfoResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{group}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get song information for a specific musical group in partitions using lexicographical order and pagination.",
+                "tags": [
+                    "API"
+                ],
+                "summary": "Get information about songs of a specific group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name of the group",
+                        "name": "group",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Title of the last song in the previous partition",
+                        "name": "prevSong",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Maximum number of songs to retrieve",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only songs released in or after this period (e.g. 2006, 2006-07, 16.07.2006)",
+                        "name": "releasedFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only songs released in or before this period (e.g. 2006, 2006-07, 16.07.2006)",
+                        "name": "releasedTo",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "description": "Format of release dates in the response",
+                        "name": "dateFormat",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy",
+                            "iso"
+                        ],
+                        "type": "string",
+                        "description": "Format of release dates in the response, if 'dateFormat' is not set",
+                        "name": "X-Date-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.SongInfoResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "List webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Webhook"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Subscribe a URL to song events (song.created, song.updated, song.deleted).",
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Register a webhook",
+                "parameters": [
+                    {
+                        "description": "URL and event types",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.WebhookCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/dead-letters": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the deliveries of all webhooks that ran out of attempts, newest first.",
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get the dead letters",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of deliveries to retrieve",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/deliveries/{id}/replay": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Send a dead or succeeded delivery again, with a fresh set of attempts.",
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Replay a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the delivery",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Delivery scheduled"
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Delivery not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Delivery is still pending",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Unsubscribe a webhook and drop its deliveries.",
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Webhook successfully deleted"
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the latest deliveries of a webhook, newest first, each with the history of its attempts.",
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Get the delivery log of a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of the webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "succeeded",
+                            "dead"
+                        ],
+                        "type": "string",
+                        "description": "Only deliveries with this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of deliveries to retrieve",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller without the required scope",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limit exceeded, see Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "cache.Stats": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "type": "integer"
+                },
+                "entries": {
+                    "type": "integer"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                }
+            }
+        },
+        "http.APIKeyCreateRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "mobile app"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "read",
+                        "write"
+                    ]
+                }
+            }
+        },
+        "http.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "limit"
+                },
+                "in": {
+                    "type": "string",
+                    "enum": [
+                        "path",
+                        "query",
+                        "header",
+                        "body"
+                    ],
+                    "example": "query"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "must be a positive integer"
+                }
+            }
+        },
+        "http.LookupCachePurgeResponse": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "http.Problem": {
+            "type": "object",
+            "properties": {
+                "cause": {
+                    "description": "Cause tells why looking up the details of a song failed.",
+                    "type": "string",
+                    "enum": [
+                        "song_not_found",
+                        "upstream_error",
+                        "upstream_timeout",
+                        "upstream_unavailable",
+                        "invalid_upstream_response",
+                        "invalid_request",
+                        "internal_error",
+                        "upstream_not_recorded"
+                    ],
+                    "example": "upstream_error"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "'limit' must be a positive integer"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.FieldError"
+                    }
+                },
+                "instance": {
+                    "description": "Instance is the request that failed.",
+                    "type": "string",
+                    "example": "/library/songs?limit=0"
+                },
+                "requestId": {
+                    "description": "RequestID is also sent in the X-Request-Id header; quote it when reporting a problem.",
+                    "type": "string",
+                    "example": "host/Nu7HqpvVrM-000001"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Invalid request parameters"
+                },
+                "type": {
+                    "description": "Type identifies the kind of problem; it is a URI reference relative to the API.",
+                    "type": "string",
+                    "example": "/problems/validation-error"
+                }
+            }
+        },
+        "http.SongAddRequest": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "releaseDate": {
+                    "description": "The details below are only accepted with source=manual.",
+                    "type": "string",
+                    "example": "16.07.2006"
+                },
+                "song": {
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
                 }
             }
         },
@@ -322,6 +2045,35 @@ const docTemplate = `{
             "properties": {
                 "id": {
                     "type": "integer"
+                },
+                "source": {
+                    "type": "string",
+                    "example": "external-api"
+                }
+            }
+        },
+        "http.SongInfoResponse": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "releaseDate": {
+                    "type": "string",
+                    "example": "16.07.2006"
+                },
+                "song": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string",
+                    "example": "external-api"
                 }
             }
         },
@@ -333,6 +2085,202 @@ const docTemplate = `{
                 }
             }
         },
+        "http.WebhookCreateRequest": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "song.created",
+                        "song.deleted"
+                    ]
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/songs"
+                }
+            }
+        },
+        "models.APIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.DeliveryAttempt": {
+            "type": "object",
+            "properties": {
+                "attemptedAt": {
+                    "type": "string"
+                },
+                "durationMs": {
+                    "type": "number"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is the status of the delivery after the attempt.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.DeliveryStatus"
+                        }
+                    ]
+                },
+                "statusCode": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.DeliveryStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "succeeded",
+                "dead"
+            ],
+            "x-enum-varnames": [
+                "DeliveryPending",
+                "DeliverySucceeded",
+                "DeliveryDead"
+            ]
+        },
+        "models.DiffStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "applied",
+                "rejected"
+            ],
+            "x-enum-varnames": [
+                "DiffPending",
+                "DiffApplied",
+                "DiffRejected"
+            ]
+        },
+        "models.Job": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "group": {
+                    "type": "string",
+                    "example": "Muse"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "5f0c6b1e-8f4a-4a36-9a53-7c1e0b6d2f4e"
+                },
+                "song": {
+                    "type": "string",
+                    "example": "Supermassive Black Hole"
+                },
+                "songId": {
+                    "type": "integer"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.JobStatus"
+                        }
+                    ],
+                    "example": "pending"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "succeeded",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "JobPending",
+                "JobSucceeded",
+                "JobFailed"
+            ]
+        },
+        "models.SongDiff": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string",
+                    "example": "link"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "newValue": {
+                    "type": "string"
+                },
+                "oldValue": {
+                    "type": "string"
+                },
+                "resolvedAt": {
+                    "type": "string"
+                },
+                "runId": {
+                    "type": "integer"
+                },
+                "songId": {
+                    "type": "integer"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.DiffStatus"
+                        }
+                    ],
+                    "example": "pending"
+                }
+            }
+        },
         "models.SongInfo": {
             "type": "object",
             "properties": {
@@ -346,12 +2294,167 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "releaseDate": {
-                    "type": "string"
+                    "type": "string",
+                    "example": "16.07.2006"
                 },
                 "song": {
                     "type": "string"
+                },
+                "source": {
+                    "description": "Source names where the details of the song came from:\na song detail provider, or SongSourceManual.",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SyncRun": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "type": "integer"
+                },
+                "checked": {
+                    "type": "integer"
+                },
+                "diffs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SongDiff"
+                    }
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string",
+                    "example": "manual"
+                }
+            }
+        },
+        "models.Webhook": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "eventId": {
+                    "type": "integer"
+                },
+                "eventType": {
+                    "type": "string"
+                },
+                "history": {
+                    "description": "History lists the attempts of the delivery, oldest first, replays included.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DeliveryAttempt"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "lastStatusCode": {
+                    "type": "integer"
+                },
+                "nextAttemptAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.DeliveryStatus"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "webhookId": {
+                    "type": "integer"
                 }
             }
+        },
+        "rpc.BreakerState": {
+            "type": "string",
+            "enum": [
+                "closed",
+                "open",
+                "half-open"
+            ],
+            "x-enum-varnames": [
+                "BreakerClosed",
+                "BreakerOpen",
+                "BreakerHalfOpen"
+            ]
+        },
+        "rpc.BreakerStatus": {
+            "type": "object",
+            "properties": {
+                "consecutiveFailures": {
+                    "type": "integer"
+                },
+                "openedAt": {
+                    "type": "string"
+                },
+                "retryAt": {
+                    "type": "string"
+                },
+                "state": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/rpc.BreakerState"
+                        }
+                    ],
+                    "example": "closed"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "API key with the read, write or admin scope, issued through /admin/keys.",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT as \"Bearer \u003ctoken\u003e\", whose roles claim grants the read, write or admin scope.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -363,7 +2466,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/library",
 	Schemes:          []string{"http"},
 	Title:            "Song Library API",
-	Description:      "This is the API documentation for managing songs in a library.",
+	Description:      "This is the API documentation for managing songs in a library.\nErrors are reported as RFC 7807 problem details (application/problem+json).\nInvalid parameters are listed one by one in \"errors\", and every problem carries\nthe id of the request, which is also sent in the X-Request-Id header.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// KeyPrefix starts every API key, which makes leaked keys easy to spot.
+const KeyPrefix = "sl_"
+
+// prefixLength is the length of the start of a key kept in the clear,
+// so that keys can be told apart in listings.
+const prefixLength = len(KeyPrefix) + 8
+
+// GenerateKey returns a new random API key.
+func GenerateKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashKey returns the hash an API key is stored and looked up by.
+// Keys are long and random, so a fast hash is enough.
+func HashKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// DisplayPrefix returns the part of the key shown in listings.
+func DisplayPrefix(key string) string {
+	if len(key) <= prefixLength {
+		return key
+	}
+	return key[:prefixLength]
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yankokirill/song-library/internal/models"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+)
+
+// JWTVerifier checks bearer tokens signed with RS256 by the keys of a JWKS file,
+// or with HS256 by a shared secret, and maps their role claim to roles.
+type JWTVerifier struct {
+	secret     []byte
+	keys       map[string]*rsa.PublicKey
+	parser     *jwt.Parser
+	rolesClaim string
+	roleMap    map[string]string
+}
+
+type JWTOption func(*jwtOptions)
+
+type jwtOptions struct {
+	secret     []byte
+	jwksFile   string
+	issuer     string
+	audience   string
+	rolesClaim string
+	roleMap    map[string]string
+}
+
+// WithHMACSecret accepts tokens signed with HS256 by the secret.
+func WithHMACSecret(secret []byte) JWTOption {
+	return func(o *jwtOptions) {
+		o.secret = secret
+	}
+}
+
+// WithJWKSFile accepts tokens signed with RS256 by one of the RSA keys of the JWKS file.
+func WithJWKSFile(path string) JWTOption {
+	return func(o *jwtOptions) {
+		o.jwksFile = path
+	}
+}
+
+// WithIssuer requires the tokens to be issued by the issuer.
+func WithIssuer(issuer string) JWTOption {
+	return func(o *jwtOptions) {
+		o.issuer = issuer
+	}
+}
+
+// WithAudience requires the tokens to be meant for the audience.
+func WithAudience(audience string) JWTOption {
+	return func(o *jwtOptions) {
+		o.audience = audience
+	}
+}
+
+// WithRolesClaim sets the claim holding the roles of the caller,
+// either a list or a space separated string. It is "roles" by default.
+func WithRolesClaim(claim string) JWTOption {
+	return func(o *jwtOptions) {
+		o.rolesClaim = claim
+	}
+}
+
+// WithRoleMapping maps the roles of the tokens to the roles of the library.
+// Unmapped roles named like ours (read, write and admin) are kept; others are ignored.
+func WithRoleMapping(mapping map[string]string) JWTOption {
+	return func(o *jwtOptions) {
+		o.roleMap = mapping
+	}
+}
+
+func NewJWTVerifier(opts ...JWTOption) (*JWTVerifier, error) {
+	o := jwtOptions{rolesClaim: "roles"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v := &JWTVerifier{secret: o.secret, rolesClaim: o.rolesClaim, roleMap: o.roleMap}
+	var methods []string
+	if len(o.secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if o.jwksFile != "" {
+		keys, err := readJWKS(o.jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("jwt verifier needs a secret or a jwks file")
+	}
+
+	parserOpts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if o.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(o.issuer))
+	}
+	if o.audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(o.audience))
+	}
+	v.parser = jwt.NewParser(parserOpts...)
+	return v, nil
+}
+
+// Verify checks the token and returns its caller.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &Principal{ID: subject, Subject: subject, Method: MethodJWT, Roles: v.roles(claims[v.rolesClaim])}, nil
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (any, error) {
+	if token.Method == jwt.SigningMethodHS256 {
+		return v.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// roles maps the role claim, a list or a space separated string, to our roles.
+func (v *JWTVerifier) roles(claim any) []string {
+	var names []string
+	switch claim := claim.(type) {
+	case string:
+		names = strings.Fields(claim)
+	case []any:
+		for _, name := range claim {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var roles []string
+	for _, name := range names {
+		role, ok := v.roleMap[name]
+		if !ok {
+			role = name
+		}
+		if slices.Contains(models.Scopes, role) && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// readJWKS loads the RSA signing keys of a JWKS file by their ids.
+func readJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading jwks: %w", err)
+	}
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error decoding jwks %s: %w", path, err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q in %s: %w", k.Kid, path, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %q in %s: %w", k.Kid, path, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no rsa signing keys in %s", path)
+	}
+	return keys, nil
+}
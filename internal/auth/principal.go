@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"github.com/yankokirill/song-library/internal/models"
+)
+
+const (
+	MethodAPIKey = "api-key"
+	MethodJWT    = "jwt"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// ID tells the caller apart from any other: the id of the API key or the
+	// subject of the token. Names of API keys need not be unique.
+	ID string
+	// Subject is the name of the API key or the subject of the token.
+	Subject string
+	// Method tells how the caller authenticated.
+	Method string
+	// Roles are the scopes the caller is granted: read, write or admin.
+	Roles []string
+}
+
+// HasRole reports whether the caller is granted the role.
+func (p *Principal) HasRole(role string) bool {
+	return models.ScopesGrant(p.Roles, role)
+}
+
+// String identifies the caller in logs.
+func (p *Principal) String() string {
+	return p.Method + ":" + p.Subject
+}
+
+// Key identifies the caller in keyed state, such as rate limit budgets.
+func (p *Principal) Key() string {
+	return p.Method + ":" + p.ID
+}
+
+type principalContextKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// PrincipalFrom returns the caller of the request, or nil if it is anonymous.
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalContextKey{}).(*Principal)
+	return p
+}
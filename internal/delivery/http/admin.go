@@ -0,0 +1,67 @@
+package http
+
+import (
+	"github.com/yankokirill/song-library/internal/rpc"
+	"net/http"
+)
+
+// @Summary Get song cache statistics
+// @Tags Admin
+// @Description Get the hit and miss counters and the size of the song cache.
+// @Produce json
+// @Success 200 {object} cache.Stats "Cache statistics"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/cache [get]
+func (s *Server) cacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.cache.Stats())
+}
+
+// @Summary Get external API circuit breaker state
+// @Tags Admin
+// @Description Get the state of the circuit breaker guarding the external song API.
+// @Produce json
+// @Success 200 {object} rpc.BreakerStatus "Circuit breaker state"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/breaker [get]
+func (s *Server) breakerStatusHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.breaker.Status())
+}
+
+type LookupCachePurgeResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+// @Summary Purge cached song detail lookups
+// @Tags Admin
+// @Description Forget the cached answers of the song detail providers, found or not,
+// so that the next addition of the song looks it up again.
+// Without parameters the whole cache is purged. Titles and groups match regardless of case and spacing.
+// @Param song query string false "Title of the song"
+// @Param group query string false "Group of the song"
+// @Success 200 {object} LookupCachePurgeResponse "Number of purged entries"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/lookup-cache [delete]
+func (s *Server) purgeLookupCacheHandler(w http.ResponseWriter, r *http.Request) {
+	title := rpc.NormalizeKey(r.URL.Query().Get("song"))
+	group := rpc.NormalizeKey(r.URL.Query().Get("group"))
+
+	purged, err := s.lookups.PurgeLookups(r.Context(), title, group)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, LookupCachePurgeResponse{Purged: purged})
+}
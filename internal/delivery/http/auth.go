@@ -0,0 +1,104 @@
+package http
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/auth"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// authEnabled reports whether the requests must be authenticated.
+func (s *Server) authEnabled() bool {
+	return s.keys != nil || s.jwt != nil
+}
+
+// authenticate identifies the caller by the API key or the bearer token of the request.
+// Anonymous requests pass through; requireScope decides whether they may proceed.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if !s.authEnabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var caller *auth.Principal
+		if key := r.Header.Get(apiKeyHeader); key != "" && s.keys != nil {
+			var err error
+			if caller, err = s.authenticateKey(r, key); err == postgres.APIKeyNotFound {
+				unauthorized(w, r, "The API key is unknown or revoked")
+				return
+			} else if err != nil {
+				internalError(w, r, err)
+				return
+			}
+		} else if token, ok := bearerToken(r); ok && s.jwt != nil {
+			var err error
+			if caller, err = s.jwt.Verify(token); err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				unauthorized(w, r, "The bearer token is invalid: "+err.Error())
+				return
+			}
+		}
+
+		if caller != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), caller))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authenticateKey(r *http.Request, presented string) (*auth.Principal, error) {
+	hash := auth.HashKey(presented)
+	if s.bootstrapKey != nil && subtle.ConstantTimeCompare(hash, s.bootstrapKey) == 1 {
+		return &auth.Principal{ID: "bootstrap", Subject: "bootstrap", Method: auth.MethodAPIKey, Roles: []string{models.ScopeAdmin}}, nil
+	}
+
+	key, err := s.keys.FindAPIKey(r.Context(), hash)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.Principal{ID: strconv.Itoa(key.ID), Subject: key.Name, Method: auth.MethodAPIKey, Roles: key.Scopes}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// requireScope lets through the callers granted the scope.
+func (s *Server) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !s.authEnabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller := auth.PrincipalFrom(r.Context())
+			if caller == nil {
+				unauthorized(w, r, "An API key in the "+apiKeyHeader+" header or a bearer token is required")
+				return
+			}
+			if !caller.HasRole(scope) {
+				forbidden(w, r, fmt.Sprintf("The caller lacks the '%s' scope", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// audit records a change to the library and who made it.
+func audit(r *http.Request, action string, args ...any) {
+	caller := "anonymous"
+	if p := auth.PrincipalFrom(r.Context()); p != nil {
+		caller = p.String()
+	}
+	slog.InfoContext(r.Context(), action, append([]any{"audit", true, "caller", caller}, args...)...)
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+// @Summary Stream library changes
+// @Tags API
+// @Description Stream song changes as Server-Sent Events. Every event has the change id as its id,
+// the change type (song.created, song.updated or song.deleted) as its name
+// and the song information as its data.
+// To resume after a disconnect, send the id of the last received event in the Last-Event-ID header
+// (or the `lastEventId` parameter): the changes made since then are replayed first.
+// Events come in the order their changes were committed, so ids are not always increasing.
+// A change is sent once the transactions started before it are over.
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "ID of the last received event"
+// @Param lastEventId query string false "ID of the last received event, if the header cannot be set"
+// @Param dateFormat query string false "Format of release dates in the events" Enums(legacy, iso)
+// @Success 200 {object} SongInfoResponse "Stream of events"
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /events [get]
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	var invalid validationErrors
+	lastID, err := parseLastEventID(r)
+	invalid.add(err)
+	format, err := s.parseDateFormat(r)
+	invalid.add(err)
+	if err := invalid.err(); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	// Subscribe before reading the log, so that no change falls in between.
+	live, unsubscribe := s.feed.Subscribe()
+	defer unsubscribe()
+
+	var last models.ChangePosition
+	if lastID >= 0 {
+		if last, err = s.feed.Position(r.Context(), lastID); err != nil {
+			internalError(w, r, err)
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(change models.Event) error {
+		if !last.Before(change.Position()) {
+			return nil
+		}
+		if err := writeEvent(w, change, format); err != nil {
+			return err
+		}
+		last = change.Position()
+		return rc.Flush()
+	}
+
+	if lastID >= 0 {
+		for {
+			changes, err := s.feed.ChangesAfter(r.Context(), last, 100)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error replaying song changes", logging.Err(err))
+				return
+			}
+			for _, change := range changes {
+				if err := send(change); err != nil {
+					return
+				}
+			}
+			if len(changes) < 100 {
+				break
+			}
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.stopping:
+			// Clients resume from the last event they got on another instance.
+			return
+		case change, ok := <-live:
+			if !ok {
+				return
+			}
+			if err := send(change); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// parseLastEventID returns -1 if the client does not resume a stream.
+func parseLastEventID(r *http.Request) (int64, error) {
+	in, field := "header", "Last-Event-ID"
+	value := r.Header.Get(field)
+	if value == "" {
+		in, field = "query", "lastEventId"
+		value = r.URL.Query().Get(field)
+	}
+	if value == "" {
+		return -1, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, invalidField(in, field, "must be a non-negative integer")
+	}
+	return id, nil
+}
+
+func writeEvent(w http.ResponseWriter, change models.Event, format models.DateFormat) error {
+	var song models.SongInfo
+	if err := json.Unmarshal(change.Payload, &song); err != nil {
+		return fmt.Errorf("error decoding song change %d: %w", change.ID, err)
+	}
+	data, err := json.Marshal(newSongInfoResponses([]models.SongInfo{song}, format)[0])
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Type, data)
+	return err
+}
@@ -2,12 +2,12 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/yankokirill/song-library/internal/models"
 	"github.com/yankokirill/song-library/internal/repository/postgres"
 	"github.com/yankokirill/song-library/internal/rpc"
-	"log"
 	"net/http"
 	"strconv"
 )
@@ -19,99 +19,159 @@ type SongLyricsParams struct {
 }
 
 func parseSongLyricsParams(r *http.Request) (*SongLyricsParams, error) {
+	var invalid validationErrors
 	id, err := parseID(r)
-	if err != nil {
-		return nil, err
-	}
+	invalid.add(err)
 
 	offset := 0
-	offsetStr := r.URL.Query().Get("offset")
-	if offsetStr != "" {
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
 		offset, err = strconv.Atoi(offsetStr)
 		if err != nil || offset < 0 {
-			return nil, fmt.Errorf("'offset' must be a non-negative integer")
+			invalid.add(invalidField("query", "offset", "must be a non-negative integer"))
 		}
 	}
 
-	limit := 20
-	limitStr := r.URL.Query().Get("limit")
-	if limitStr != "" {
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil || limit <= 0 {
-			return nil, fmt.Errorf("'limit' must be a positive integer")
-		}
-	}
+	limit, err := parseLimit(r, 20)
+	invalid.add(err)
 
+	if err := invalid.err(); err != nil {
+		return nil, err
+	}
 	return &SongLyricsParams{ID: id, Offset: offset, Limit: limit}, nil
 }
 
 func parseSongPaginationInfo(r *http.Request) (*models.PaginationInfo, error) {
-	groupName := r.URL.Query().Get("prevGroup")
-	songTitle := r.URL.Query().Get("prevSong")
-	limitStr := r.URL.Query().Get("limit")
+	var invalid validationErrors
+	limit, err := parseLimit(r, 10)
+	invalid.add(err)
 
-	limit := 10
-	if limitStr != "" {
-		var err error
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil || limit <= 0 {
-			return nil, fmt.Errorf("invalid 'limit' parameter")
+	hint := &models.PaginationInfo{
+		PrevGroup: r.URL.Query().Get("prevGroup"),
+		PrevSong:  r.URL.Query().Get("prevSong"),
+		Limit:     limit,
+	}
+	if from := r.URL.Query().Get("releasedFrom"); from != "" {
+		if hint.ReleasedFrom, err = models.ParseReleaseDate(from); err != nil {
+			invalid.add(invalidField("query", "releasedFrom", err.Error()))
+		}
+	}
+	if to := r.URL.Query().Get("releasedTo"); to != "" {
+		if hint.ReleasedTo, err = models.ParseReleaseDate(to); err != nil {
+			invalid.add(invalidField("query", "releasedTo", err.Error()))
 		}
 	}
 
-	return &models.PaginationInfo{
-		PrevGroup: groupName,
-		PrevSong:  songTitle,
-		Limit:     limit,
-	}, nil
+	if err := invalid.err(); err != nil {
+		return nil, err
+	}
+	return hint, nil
 }
 
-func parseID(r *http.Request) (int, error) {
-	idStr := chi.URLParam(r, "id")
-	if idStr == "" {
-		return 0, fmt.Errorf("missing 'id' query parameter")
+// parseDateFormat returns the date format requested by the client
+// through the 'dateFormat' query parameter or the X-Date-Format header.
+func (s *Server) parseDateFormat(r *http.Request) (models.DateFormat, error) {
+	in, field := "query", "dateFormat"
+	format := r.URL.Query().Get(field)
+	if format == "" {
+		in, field = "header", "X-Date-Format"
+		format = r.Header.Get(field)
+	}
+	if format == "" {
+		return s.dateFormat, nil
 	}
-	id, err := strconv.Atoi(idStr)
+	parsed, err := models.ParseDateFormat(format)
 	if err != nil {
-		return 0, fmt.Errorf("error parsing 'id': %w", err)
+		return "", invalidField(in, field, err.Error())
 	}
-	if id <= 0 {
-		return 0, fmt.Errorf("'id' query parameter must be a positive integer")
+	return parsed, nil
+}
+
+func parseID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		return 0, invalidField("path", "id", "must be a positive integer")
 	}
 	return id, nil
 }
 
+func parseLimit(r *http.Request, defaultLimit int) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return 0, invalidField("query", "limit", "must be a positive integer")
+	}
+	return limit, nil
+}
+
+type SongInfoResponse struct {
+	ID          int    `json:"id"`
+	Title       string `json:"song"`
+	Group       string `json:"group"`
+	ReleaseDate string `json:"releaseDate" example:"16.07.2006"`
+	Link        string `json:"link"`
+	Source      string `json:"source" example:"external-api"`
+}
+
+func newSongInfoResponses(songs []models.SongInfo, format models.DateFormat) []SongInfoResponse {
+	resp := make([]SongInfoResponse, 0, len(songs))
+	for _, song := range songs {
+		resp = append(resp, SongInfoResponse{
+			ID:          song.ID,
+			Title:       song.Title,
+			Group:       song.Group,
+			ReleaseDate: song.ReleaseDate.Format(format),
+			Link:        song.Link,
+			Source:      song.Source,
+		})
+	}
+	return resp
+}
+
 // @Summary Get information about songs
 // @Tags API
 // @Description Get song information in partitions using lexicographical order and pagination.
 // Provide the `prevSong` and `prevGroup` parameters to define the starting point for the next partition.
 // If these parameters are not provided, retrieval starts from the first song in the library.
+// Release dates may be partial (year or month and year); a song matches a release date filter
+// only if its whole release period lies within the requested range.
+// Dates are accepted in ISO 8601 (2006-07-16, 2006-07, 2006) or legacy (16.07.2006, 07.2006, 2006) format.
 // @Param prevSong query string false "Title of the last song in the previous partition"
 // @Param prevGroup query string false "Group of the last song in the previous partition"
 // @Param limit query int false "Maximum number of songs to retrieve" default(10)
-// @Success 200 {object} []models.SongInfo
-// @Failure 400 {string} string "Invalid request"
-// @Failure 500 {string} string "Internal Server Error"
+// @Param releasedFrom query string false "Only songs released in or after this period (e.g. 2006, 2006-07, 16.07.2006)"
+// @Param releasedTo query string false "Only songs released in or before this period (e.g. 2006, 2006-07, 16.07.2006)"
+// @Param dateFormat query string false "Format of release dates in the response" Enums(legacy, iso)
+// @Param X-Date-Format header string false "Format of release dates in the response, if 'dateFormat' is not set" Enums(legacy, iso)
+// @Success 200 {object} []SongInfoResponse
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /songs [get]
 func (s *Server) getSongsInfoHandler(w http.ResponseWriter, r *http.Request) {
+	var invalid validationErrors
 	hint, err := parseSongPaginationInfo(r)
-	if err != nil {
-		http.Error(w, "Invalid Request", http.StatusBadRequest)
+	invalid.add(err)
+	format, err := s.parseDateFormat(r)
+	invalid.add(err)
+	if err := invalid.err(); err != nil {
+		badRequest(w, r, err)
 		return
 	}
 
-	songs, err := s.db.GetSongsInfo(hint)
+	songs, err := s.db.GetSongsInfo(r.Context(), hint)
 	if err != nil {
-		http.Error(w, "Failed to fetch songs", http.StatusInternalServerError)
-		log.Printf("%v", err)
+		internalError(w, r, fmt.Errorf("error fetching songs: %w", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(songs); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		log.Printf("error encoding response: %v", err)
-	}
+	writeJSON(w, http.StatusOK, newSongInfoResponses(songs, format))
 }
 
 // @Summary Get information about songs of a specific group
@@ -119,33 +179,44 @@ func (s *Server) getSongsInfoHandler(w http.ResponseWriter, r *http.Request) {
 // @Description Get song information for a specific musical group in partitions using lexicographical order and pagination.
 // Provide the `prevSong` parameter to define the starting point for the next partition.
 // If this parameter is not provided, retrieval starts from the first song of the specified group.
+// Release dates may be partial (year or month and year); a song matches a release date filter
+// only if its whole release period lies within the requested range.
+// Dates are accepted in ISO 8601 (2006-07-16, 2006-07, 2006) or legacy (16.07.2006, 07.2006, 2006) format.
 // @Param group path string true "Name of the group"
 // @Param prevSong query string false "Title of the last song in the previous partition"
 // @Param limit query int false "Maximum number of songs to retrieve" default(10)
-// @Success 200 {object} []models.SongInfo
-// @Failure 400 {string} string "Invalid request"
-// @Failure 500 {string} string "Internal Server Error"
+// @Param releasedFrom query string false "Only songs released in or after this period (e.g. 2006, 2006-07, 16.07.2006)"
+// @Param releasedTo query string false "Only songs released in or before this period (e.g. 2006, 2006-07, 16.07.2006)"
+// @Param dateFormat query string false "Format of release dates in the response" Enums(legacy, iso)
+// @Param X-Date-Format header string false "Format of release dates in the response, if 'dateFormat' is not set" Enums(legacy, iso)
+// @Success 200 {object} []SongInfoResponse
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /songs/{group} [get]
 func (s *Server) getGroupSongsInfoHandler(w http.ResponseWriter, r *http.Request) {
 	group := chi.URLParam(r, "group")
+	var invalid validationErrors
 	hint, err := parseSongPaginationInfo(r)
-	if err != nil {
-		http.Error(w, "Invalid Request", http.StatusBadRequest)
+	invalid.add(err)
+	format, err := s.parseDateFormat(r)
+	invalid.add(err)
+	if err := invalid.err(); err != nil {
+		badRequest(w, r, err)
 		return
 	}
 
-	songs, err := s.db.GetGroupSongsInfo(group, hint)
+	songs, err := s.db.GetGroupSongsInfo(r.Context(), group, hint)
 	if err != nil {
-		http.Error(w, "Failed to fetch songs", http.StatusInternalServerError)
-		log.Printf("%v", err)
+		internalError(w, r, fmt.Errorf("error fetching songs: %w", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(songs); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		log.Printf("error encoding response: %v", err)
-	}
+	writeJSON(w, http.StatusOK, newSongInfoResponses(songs, format))
 }
 
 type SongLyricsResponse struct {
@@ -159,131 +230,176 @@ type SongLyricsResponse struct {
 // @Param offset query int false "Offset for starting from a specific verse" default(0)
 // @Param limit query int false "Maximum number of verses to retrieve" default(20)
 // @Success 200 {object} SongLyricsResponse
-// @Failure 400 {string} string "Invalid request"
-// @Failure 404 {string} string "Song not found"
-// @Failure 500 {string} string "Internal Server Error"
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Song not found"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /song/{id} [get]
 func (s *Server) getSongLyricsHandler(w http.ResponseWriter, r *http.Request) {
 	params, err := parseSongLyricsParams(r)
 	if err != nil {
-		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		badRequest(w, r, err)
 		return
 	}
 
-	lyrics, err := s.db.GetSongLyrics(params.ID, params.Offset, params.Limit)
+	lyrics, err := s.db.GetSongLyrics(r.Context(), params.ID, params.Offset, params.Limit)
 	if err != nil {
 		if err == postgres.SongNotFound {
-			http.Error(w, "Song Not Found", http.StatusNotFound)
+			notFound(w, r, fmt.Sprintf("Song %d does not exist", params.ID))
 		} else {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			log.Printf("error fetching song lyrics: %v", err)
+			internalError(w, r, fmt.Errorf("error fetching song lyrics: %w", err))
 		}
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	resp := SongLyricsResponse{lyrics}
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		log.Printf("error encoding response: %v", err)
-	}
+	writeJSON(w, http.StatusOK, SongLyricsResponse{lyrics})
 }
 
 type SongAddRequest struct {
 	Song  string `json:"song"`
 	Group string `json:"group"`
+	// The details below are only accepted with source=manual.
+	ReleaseDate string `json:"releaseDate,omitempty" example:"16.07.2006"`
+	Text        string `json:"text,omitempty"`
+	Link        string `json:"link,omitempty"`
+}
+
+func (req *SongAddRequest) hasDetails() bool {
+	return req.ReleaseDate != "" || req.Text != "" || req.Link != ""
 }
 
 type SongAddResponse struct {
-	ID int `json:"id"`
+	ID     int    `json:"id"`
+	Source string `json:"source" example:"external-api"`
 }
 
 // @Summary Add a new song
 // @Tags API
 // @Description Add a new song to the library with the given title and group.
-// @Param song body SongAddRequest true "Title and group"
+// The release date, lyrics and link are looked up by the configured song detail providers;
+// the response names the provider that supplied them.
+// With async=true the song is added in the background and the response describes the job
+// to poll at /jobs/{id}.
+// With source=manual the providers are skipped: the request must carry the release date,
+// lyrics and, optionally, link of the song, which are validated like the providers' details.
+// Manual songs are always added synchronously and are never re-synced.
+// Failures are described by an error body whose cause tells a song unknown upstream (404)
+// from a failing API (502), an API that timed out (504) and one that is unavailable (503).
+// @Param song body SongAddRequest true "Title and group, and the details of a manual song"
+// @Param async query bool false "Add the song in the background"
+// @Param source query string false "Where the song details come from" Enums(manual)
 // @Success 201 {object} SongAddResponse
-// @Failure 400 {string} string "Invalid request"
-// @Failure 500 {string} string "Internal Server Error"
+// @Success 202 {object} models.Job "Job adding the song"
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Song unknown to the providers"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Failure 502 {object} Problem "External API failed or returned invalid details"
+// @Failure 503 {object} Problem "External API unavailable or rate limited, see Retry-After"
+// @Failure 504 {object} Problem "External API timed out"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /song [post]
 func (s *Server) addSongHandler(w http.ResponseWriter, r *http.Request) {
 	var req SongAddRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
-		log.Printf("Failed to decode JSON payload: %v", err)
+		invalidJSON(w, r, err)
 		return
 	}
 
-	if req.Song == "" || req.Group == "" {
-		http.Error(w, "Missing 'song' or 'group' query parameter", http.StatusBadRequest)
-		return
+	var invalid validationErrors
+	if req.Song == "" {
+		invalid.add(invalidField("body", "song", "is required"))
 	}
-
-	songDetail, Err := rpc.GetSongDetail(req.Song, req.Group)
-	if Err != nil {
-		http.Error(w, Err.Status, Err.StatusCode)
-		log.Println(Err.LogErr)
+	if req.Group == "" {
+		invalid.add(invalidField("body", "group", "is required"))
+	}
+	if err := invalid.err(); err != nil {
+		badRequest(w, r, err)
 		return
 	}
 
-	song := &models.Song{
-		SongInfo: models.SongInfo{
-			Title:       req.Song,
-			Group:       req.Group,
-			ReleaseDate: songDetail.ReleaseDate,
-			Link:        songDetail.Link,
-		},
-		Lyrics: songDetail.Text,
+	async := r.URL.Query().Get("async") == "true"
+	var song *models.Song
+	var source string
+	var Err *rpc.HttpError
+	switch r.URL.Query().Get("source") {
+	case "":
+		if req.hasDetails() {
+			badRequest(w, r, errors.New("song details are only accepted with source=manual"))
+			return
+		}
+		if async {
+			s.addSongAsync(w, r, &req)
+			return
+		}
+		song, source, Err = s.songs.AddSong(r.Context(), req.Song, req.Group)
+	case models.SongSourceManual:
+		if async {
+			badRequest(w, r, invalidField("query", "async", "manual songs cannot be added asynchronously"))
+			return
+		}
+		detail := &models.SongDetail{ReleaseDate: req.ReleaseDate, Text: req.Text, Link: req.Link}
+		song, Err = s.songs.AddManualSong(r.Context(), req.Song, req.Group, detail)
+		source = models.SongSourceManual
+	default:
+		badRequest(w, r, invalidField("query", "source", "must be empty or manual"))
+		return
 	}
-	id, err := s.db.AddSong(song)
-	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("failed to add song %v", err)
+	if Err != nil {
+		songDetailProblem(w, r, Err)
 		return
 	}
 
-	resp := SongAddResponse{id}
-	w.WriteHeader(http.StatusCreated)
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("failed to write response: %v", err)
-	}
+	audit(r, "song added", "song_id", song.ID)
+	writeJSON(w, http.StatusCreated, SongAddResponse{ID: song.ID, Source: source})
 }
 
 // @Summary Update an existing song
 // @Tags API
 // @Description Update the details of an existing song, identified by its ID.
 // The request body must contain the fields to be updated (e.g., song title, group, release date, or link).
+// The release date may be a year (2006), a month (2006-07 or 07.2006) or a day (2006-07-16 or 16.07.2006).
 // The song lyrics cannot be modified through this request.
 // @Param id path int true "ID of the song to be updated"
 // @Param song body models.SongInfo true "Updated song details"
 // @Success 200 {object} models.SongInfo "Updated song details"
-// @Failure 400 {string} string "Invalid request"
-// @Failure 404 {string} string "Song not found"
-// @Failure 500 {string} string "Internal Server Error"
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Song not found"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /song/{id} [put]
 func (s *Server) updateSongHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := parseID(r)
 	if err != nil {
-		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		badRequest(w, r, err)
 		return
 	}
 	song := &models.SongInfo{ID: id}
 	if err := json.NewDecoder(r.Body).Decode(song); err != nil {
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
-		log.Printf("failed to decode request body: %v", err)
+		invalidJSON(w, r, err)
 		return
 	}
 
-	if err := s.db.UpdateSongInfo(song); err != nil {
+	if err := s.db.UpdateSongInfo(r.Context(), song); err != nil {
 		if err == postgres.SongNotFound {
-			http.Error(w, "Song Not Found", http.StatusNotFound)
+			notFound(w, r, fmt.Sprintf("Song %d does not exist", id))
 		} else {
-			http.Error(w, "Failed to update song information", http.StatusInternalServerError)
-			log.Printf("error updating song with id %d: %v", song.ID, err)
+			internalError(w, r, fmt.Errorf("error updating song with id %d: %w", song.ID, err))
 		}
+		return
 	}
+	audit(r, "song updated", "song_id", id)
 }
 
 // @Summary Delete a song
@@ -292,21 +408,26 @@ func (s *Server) updateSongHandler(w http.ResponseWriter, r *http.Request) {
 // Once deleted, the song and its details will be permanently removed from the database.
 // @Param id path int true "ID of the song to be deleted"
 // @Success 204 "Song successfully deleted"
-// @Failure 400 {string} string "Invalid request"
-// @Failure 500 {string} string "Internal Server Error"
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /song/{id} [delete]
 func (s *Server) deleteSongHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := parseID(r)
 	if err != nil {
-		http.Error(w, "Invalid Request", http.StatusBadRequest)
+		badRequest(w, r, err)
 		return
 	}
 
-	if err := s.db.DeleteSong(id); err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("%v", err)
+	if err := s.db.DeleteSong(r.Context(), id); err != nil {
+		internalError(w, r, err)
 		return
 	}
+	audit(r, "song deleted", "song_id", id)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"net/http"
+)
+
+type HealthResponse struct {
+	Status string `json:"status" example:"ok"`
+}
+
+// healthzHandler tells that the process is alive and serving.
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// readyzHandler tells whether the dependencies of the service are usable,
+// with the status and latency of every check, answering 503 if any is not
+// or while the server drains.
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	report := s.health.Check(r.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
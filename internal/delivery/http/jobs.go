@@ -0,0 +1,61 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"net/http"
+)
+
+func (s *Server) addSongAsync(w http.ResponseWriter, r *http.Request, req *SongAddRequest) {
+	if s.jobs == nil {
+		badRequest(w, r, invalidField("query", "async", "asynchronous mode is not enabled"))
+		return
+	}
+
+	job := &models.Job{Title: req.Song, Group: req.Group}
+	if err := s.jobs.CreateJob(r.Context(), job); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	audit(r, "song job queued", "job_id", job.ID)
+	w.Header().Set("Location", "/library/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// @Summary Get a background job
+// @Tags API
+// @Description Get the status of a song being added in the background:
+// pending, succeeded with the id of the song, or failed with the reason.
+// @Param id path string true "ID of the job"
+// @Success 200 {object} models.Job
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Job not found"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /jobs/{id} [get]
+func (s *Server) getJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		badRequest(w, r, invalidField("path", "id", "must be a UUID"))
+		return
+	}
+
+	job, err := s.jobs.GetJob(r.Context(), id)
+	if errors.Is(err, postgres.JobNotFound) {
+		notFound(w, r, fmt.Sprintf("Job %s does not exist", id))
+		return
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/auth"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type APIKeyCreateRequest struct {
+	Name   string   `json:"name" example:"mobile app"`
+	Scopes []string `json:"scopes" example:"read,write"`
+}
+
+func (req *APIKeyCreateRequest) validate() error {
+	var errs validationErrors
+	if strings.TrimSpace(req.Name) == "" {
+		errs.add(invalidField("body", "name", "is required"))
+	}
+	if len(req.Scopes) == 0 {
+		errs.add(invalidField("body", "scopes", "must not be empty"))
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.Scopes, scope) {
+			errs.add(invalidField("body", "scopes", fmt.Sprintf("unknown scope %q", scope)))
+		}
+	}
+	return errs.err()
+}
+
+// @Summary Issue an API key
+// @Tags Admin
+// @Description Issue a key with the given scopes: read, write (which includes read) or admin (which includes all).
+// The key is only returned by this request; afterwards it is known by its id and prefix.
+// @Accept json
+// @Produce json
+// @Param key body APIKeyCreateRequest true "Name and scopes of the key"
+// @Success 201 {object} models.APIKey
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/keys [post]
+func (s *Server) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req APIKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	secret, err := auth.GenerateKey()
+	if err != nil {
+		internalError(w, r, fmt.Errorf("error generating api key: %w", err))
+		return
+	}
+	key := &models.APIKey{Name: req.Name, Prefix: auth.DisplayPrefix(secret), Scopes: req.Scopes}
+	if err := s.keys.CreateAPIKey(r.Context(), key, auth.HashKey(secret)); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	key.Key = secret
+	writeJSON(w, http.StatusCreated, key)
+}
+
+// @Summary List API keys
+// @Tags Admin
+// @Description List the issued keys, revoked ones included, without the keys themselves.
+// @Produce json
+// @Success 200 {object} []models.APIKey
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/keys [get]
+func (s *Server) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.keys.ListAPIKeys(r.Context())
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// @Summary Revoke an API key
+// @Tags Admin
+// @Description Revoke a key; requests made with it are rejected from now on.
+// @Param id path int true "ID of the key"
+// @Success 204 "Key successfully revoked"
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Key not found or already revoked"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/keys/{id} [delete]
+func (s *Server) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if err := s.keys.RevokeAPIKey(r.Context(), id); err != nil {
+		if err == postgres.APIKeyNotFound {
+			notFound(w, r, fmt.Sprintf("API key %d does not exist or is already revoked", id))
+		} else {
+			internalError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
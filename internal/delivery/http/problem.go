@@ -0,0 +1,201 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Problem is the body of every error response, as described by RFC 7807,
+// sent with the application/problem+json content type.
+type Problem struct {
+	// Type identifies the kind of problem; it is a URI reference relative to the API.
+	Type   string `json:"type" example:"/problems/validation-error"`
+	Title  string `json:"title" example:"Invalid request parameters"`
+	Status int    `json:"status" example:"400"`
+	Detail string `json:"detail,omitempty" example:"'limit' must be a positive integer"`
+	// Instance is the request that failed.
+	Instance string `json:"instance,omitempty" example:"/library/songs?limit=0"`
+	// RequestID is also sent in the X-Request-Id header; quote it when reporting a problem.
+	RequestID string `json:"requestId,omitempty" example:"host/Nu7HqpvVrM-000001"`
+	// Cause tells why looking up the details of a song failed.
+	Cause  string       `json:"cause,omitempty" example:"upstream_error" enums:"song_not_found,upstream_error,upstream_timeout,upstream_unavailable,invalid_upstream_response,invalid_request,internal_error,upstream_not_recorded"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes an invalid request parameter or body field.
+type FieldError struct {
+	Field   string `json:"field" example:"limit"`
+	In      string `json:"in" example:"query" enums:"path,query,header,body"`
+	Message string `json:"message" example:"must be a positive integer"`
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid %s parameter '%s': %s", e.In, e.Field, e.Message)
+}
+
+func invalidField(in, field, message string) *FieldError {
+	return &FieldError{Field: field, In: in, Message: message}
+}
+
+// validationErrors collects the invalid fields of a request.
+type validationErrors []FieldError
+
+// add collects the invalid fields of err, if any.
+func (v *validationErrors) add(err error) {
+	var fields validationErrors
+	var field *FieldError
+	switch {
+	case errors.As(err, &fields):
+		*v = append(*v, fields...)
+	case errors.As(err, &field):
+		*v = append(*v, *field)
+	}
+}
+
+func (v validationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v validationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i := range v {
+		messages[i] = v[i].Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+const (
+	problemValidation   = "/problems/validation-error"
+	problemBadRequest   = "/problems/bad-request"
+	problemNotFound     = "/problems/not-found"
+	problemConflict     = "/problems/conflict"
+	problemUnauthorized = "/problems/unauthorized"
+	problemForbidden    = "/problems/forbidden"
+	problemRateLimited  = "/problems/rate-limited"
+	problemInternal     = "/problems/internal-error"
+)
+
+// writeProblem completes the problem with the request and sends it.
+func writeProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.RequestURI()
+	p.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		slog.ErrorContext(r.Context(), "error encoding problem", logging.Err(err))
+	}
+}
+
+// badRequest reports invalid parameters, field by field when the error tells which.
+func badRequest(w http.ResponseWriter, r *http.Request, err error) {
+	p := Problem{Type: problemBadRequest, Title: "Invalid request", Status: http.StatusBadRequest, Detail: err.Error()}
+
+	var fields validationErrors
+	fields.add(err)
+	if len(fields) > 0 {
+		p.Errors = fields
+		p.Type = problemValidation
+		p.Title = "Invalid request parameters"
+	}
+	writeProblem(w, r, p)
+}
+
+// invalidJSON reports a request body that cannot be decoded.
+func invalidJSON(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, r, Problem{
+		Type:   problemBadRequest,
+		Title:  "Invalid JSON payload",
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+	})
+}
+
+func notFound(w http.ResponseWriter, r *http.Request, detail string) {
+	writeProblem(w, r, Problem{Type: problemNotFound, Title: "Not found", Status: http.StatusNotFound, Detail: detail})
+}
+
+func conflict(w http.ResponseWriter, r *http.Request, detail string) {
+	writeProblem(w, r, Problem{Type: problemConflict, Title: "Conflict", Status: http.StatusConflict, Detail: detail})
+}
+
+// unauthorized asks the client to authenticate.
+func unauthorized(w http.ResponseWriter, r *http.Request, detail string) {
+	if w.Header().Get("WWW-Authenticate") == "" {
+		w.Header().Set("WWW-Authenticate", `ApiKey header="X-API-Key", Bearer`)
+	}
+	writeProblem(w, r, Problem{Type: problemUnauthorized, Title: "Unauthorized", Status: http.StatusUnauthorized, Detail: detail})
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, detail string) {
+	writeProblem(w, r, Problem{Type: problemForbidden, Title: "Forbidden", Status: http.StatusForbidden, Detail: detail})
+}
+
+// internalError logs the error and reports it without its details,
+// which are only useful to the operators.
+func internalError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, logging.Err(err))
+	writeProblem(w, r, Problem{
+		Type:   problemInternal,
+		Title:  "Internal server error",
+		Status: http.StatusInternalServerError,
+		Detail: "The request failed on our side; quote the request id when reporting it.",
+	})
+}
+
+// songDetailProblem reports a failed lookup or storage of a song.
+func songDetailProblem(w http.ResponseWriter, r *http.Request, Err *rpc.HttpError) {
+	if Err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(Err.RetryAfter.Seconds()))))
+	}
+	slog.WarnContext(r.Context(), "song detail lookup failed", "status", Err.StatusCode, "cause", Err.Cause, logging.Err(Err.LogErr))
+
+	p := Problem{
+		Type:   "/problems/" + strings.ReplaceAll(Err.Cause, "_", "-"),
+		Title:  http.StatusText(Err.StatusCode),
+		Status: Err.StatusCode,
+		Detail: Err.Status,
+		Cause:  Err.Cause,
+	}
+	if Err.Cause == "" {
+		p.Type = ""
+	}
+	var invalid *models.InvalidFieldError
+	if Err.StatusCode == http.StatusBadRequest && errors.As(Err.LogErr, &invalid) {
+		p.Type = problemValidation
+		p.Title = "Invalid request parameters"
+		p.Errors = []FieldError{{Field: invalid.Field, In: "body", Message: invalid.Message}}
+	}
+	writeProblem(w, r, p)
+}
+
+// requestID sends the id of the request back in the X-Request-Id header
+// and tags the context with it, for the logs and the calls to other services.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(logging.RequestIDHeader, id)
+			r = r.WithContext(logging.WithRequestID(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"fmt"
+	"github.com/yankokirill/song-library/internal/auth"
+	"github.com/yankokirill/song-library/internal/logging"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// rateLimit spends a token of the client's read budget on GET requests,
+// and of its write budget on the others. Clients are told apart by their
+// credentials, or by their address when anonymous.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	if s.limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class, limit := "read", s.readLimit
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			class, limit = "write", s.writeLimit
+		}
+
+		decision, err := s.limiter.Take(r.Context(), class+":"+rateLimitClient(r), limit)
+		if err != nil {
+			// A failing store must not take the API down with it.
+			slog.ErrorContext(r.Context(), "rate limit store failed", logging.Err(err))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(decision.Reset)))
+		if !decision.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(decision.RetryAfter)))
+			writeProblem(w, r, Problem{
+				Type:   problemRateLimited,
+				Title:  "Too many requests",
+				Status: http.StatusTooManyRequests,
+				Detail: fmt.Sprintf("The %s budget of %d requests is spent; retry after %s", class, limit.Burst, decision.RetryAfter.Round(time.Millisecond)),
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func rateLimitClient(r *http.Request) string {
+	if caller := auth.PrincipalFrom(r.Context()); caller != nil {
+		return caller.Key()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds rounds the duration up to whole seconds, as the headers expect.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -5,25 +5,105 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
 	_ "github.com/yankokirill/song-library/docs"
+	"github.com/yankokirill/song-library/internal/models"
 	"net/http"
 )
 
 func (s *Server) Routes() http.Handler {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	r.Use(traceRequests)
+	r.Use(middleware.RequestID)
+	r.Use(requestID)
+	r.Use(logRequests)
+	r.Use(s.measure)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		notFound(w, r, "No such resource")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, Problem{Status: http.StatusMethodNotAllowed, Detail: r.Method + " is not allowed here"})
+	})
 
 	r.Route("/library", func(r chi.Router) {
-		r.Get("/songs", s.getSongsInfoHandler)
-		r.Get("/songs/{group}", s.getGroupSongsInfoHandler)
-		r.Get("/song/{id}", s.getSongLyricsHandler)
+		r.Use(s.authenticate)
+		r.Use(s.rateLimit)
+		r.Use(session)
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireScope(models.ScopeRead))
+
+			r.Get("/songs", s.getSongsInfoHandler)
+			r.Get("/songs/{group}", s.getGroupSongsInfoHandler)
+			r.Get("/song/{id}", s.getSongLyricsHandler)
+
+			if s.jobs != nil {
+				r.Get("/jobs/{id}", s.getJobHandler)
+			}
+
+			if s.feed != nil {
+				r.Get("/events", s.eventsHandler)
+			}
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireScope(models.ScopeWrite))
+
+			r.Post("/song", s.addSongHandler)
+			r.Put("/song/{id}", s.updateSongHandler)
+
+			r.Delete("/song/{id}", s.deleteSongHandler)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireScope(models.ScopeAdmin))
 
-		r.Post("/song", s.addSongHandler)
-		r.Put("/song/{id}", s.updateSongHandler)
+			if s.webhooks != nil {
+				r.Route("/webhooks", func(r chi.Router) {
+					r.Post("/", s.createWebhookHandler)
+					r.Get("/", s.listWebhooksHandler)
+					r.Get("/dead-letters", s.listDeadLettersHandler)
+					r.Delete("/{id}", s.deleteWebhookHandler)
+					r.Get("/{id}/deliveries", s.listWebhookDeliveriesHandler)
+					r.Post("/deliveries/{id}/replay", s.replayWebhookDeliveryHandler)
+				})
+			}
 
-		r.Delete("/song/{id}", s.deleteSongHandler)
+			if s.keys != nil {
+				r.Route("/admin/keys", func(r chi.Router) {
+					r.Post("/", s.createAPIKeyHandler)
+					r.Get("/", s.listAPIKeysHandler)
+					r.Delete("/{id}", s.revokeAPIKeyHandler)
+				})
+			}
+			if s.cache != nil {
+				r.Get("/admin/cache", s.cacheStatsHandler)
+			}
+			if s.breaker != nil {
+				r.Get("/admin/breaker", s.breakerStatusHandler)
+			}
+			if s.lookups != nil {
+				r.Delete("/admin/lookup-cache", s.purgeLookupCacheHandler)
+			}
+			if s.syncer != nil {
+				r.Route("/admin/sync", func(r chi.Router) {
+					r.Post("/", s.triggerSyncHandler)
+					r.Get("/runs", s.listSyncRunsHandler)
+					r.Get("/runs/{id}", s.getSyncRunHandler)
+					r.Get("/diffs", s.listSongDiffsHandler)
+					r.Post("/diffs/{id}/apply", s.applySongDiffHandler)
+					r.Post("/diffs/{id}/reject", s.rejectSongDiffHandler)
+				})
+			}
+		})
 	})
 
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
+	if s.metrics != nil {
+		r.Handle("/metrics", s.metrics.Handler())
+	}
+	r.Get("/healthz", s.healthzHandler)
+	if s.health != nil {
+		r.Get("/readyz", s.readyzHandler)
+	}
 	return r
 }
@@ -2,8 +2,19 @@ package http
 
 import (
 	"context"
+	"github.com/yankokirill/song-library/internal/auth"
+	"github.com/yankokirill/song-library/internal/events"
+	"github.com/yankokirill/song-library/internal/health"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/metrics"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/ratelimit"
+	"github.com/yankokirill/song-library/internal/repository/cache"
 	"github.com/yankokirill/song-library/internal/repository/postgres"
-	"log"
+	"github.com/yankokirill/song-library/internal/resync"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"github.com/yankokirill/song-library/internal/service"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,18 +23,169 @@ import (
 )
 
 type Server struct {
-	db      postgres.SongRepository
-	address string
+	db         postgres.SongRepository
+	songs      *service.SongService
+	address    string
+	dateFormat models.DateFormat
+	webhooks   postgres.WebhookRepository
+	feed       *events.Feed
+	cache      *cache.SongRepository
+	breaker    *rpc.CircuitBreaker
+	jobs       postgres.JobRepository
+	syncer     *resync.Syncer
+	sync       postgres.SyncRepository
+	lookups    postgres.LookupCacheRepository
+	keys       postgres.APIKeyRepository
+	jwt        *auth.JWTVerifier
+	limiter    ratelimit.Store
+	metrics    *metrics.Metrics
+	health     *health.Checker
+	drainDelay time.Duration
+	server     *http.Server
+	// stopping is closed when the server shuts down, to end the event streams.
+	stopping   chan struct{}
+	readLimit  ratelimit.Limit
+	writeLimit ratelimit.Limit
+	// bootstrapKey is the hash of the admin key from the configuration.
+	bootstrapKey []byte
 }
 
-func NewServer(db postgres.SongRepository, address string) *Server {
-	return &Server{db, address}
+type ServerOption func(*Server)
+
+// WithDateFormat sets the format of dates in responses
+// when the client does not ask for a specific one.
+func WithDateFormat(format models.DateFormat) ServerOption {
+	return func(s *Server) {
+		s.dateFormat = format
+	}
+}
+
+// WithWebhooks enables the webhook management endpoints.
+func WithWebhooks(webhooks postgres.WebhookRepository) ServerOption {
+	return func(s *Server) {
+		s.webhooks = webhooks
+	}
+}
+
+// WithEventFeed enables the stream of library changes.
+func WithEventFeed(feed *events.Feed) ServerOption {
+	return func(s *Server) {
+		s.feed = feed
+	}
+}
+
+// WithJobs enables adding songs in the background.
+func WithJobs(jobs postgres.JobRepository) ServerOption {
+	return func(s *Server) {
+		s.jobs = jobs
+	}
+}
+
+// WithSync enables the endpoints to run song syncs and review their differences.
+func WithSync(syncer *resync.Syncer, sync postgres.SyncRepository) ServerOption {
+	return func(s *Server) {
+		s.syncer = syncer
+		s.sync = sync
+	}
+}
+
+// WithLookupCache enables purging the cache of song detail lookups.
+func WithLookupCache(lookups postgres.LookupCacheRepository) ServerOption {
+	return func(s *Server) {
+		s.lookups = lookups
+	}
+}
+
+// WithAPIKeys requires an API key with the right scope on every request,
+// unless the request carries a bearer token accepted by WithJWT.
+// The bootstrap key, if not empty, is accepted as an admin key
+// to issue the first keys.
+func WithAPIKeys(keys postgres.APIKeyRepository, bootstrapKey string) ServerOption {
+	return func(s *Server) {
+		s.keys = keys
+		if bootstrapKey != "" {
+			s.bootstrapKey = auth.HashKey(bootstrapKey)
+		}
+	}
+}
+
+// WithJWT accepts bearer tokens checked by the verifier, alongside any API keys.
+func WithJWT(verifier *auth.JWTVerifier) ServerOption {
+	return func(s *Server) {
+		s.jwt = verifier
+	}
+}
+
+// WithRateLimit limits the requests of every client, with separate budgets
+// for reads and writes, keeping the buckets in the store.
+func WithRateLimit(store ratelimit.Store, read, write ratelimit.Limit) ServerOption {
+	return func(s *Server) {
+		s.limiter = store
+		s.readLimit = read
+		s.writeLimit = write
+	}
+}
+
+// WithMetrics records the requests served in the metrics,
+// and exposes them at /metrics.
+func WithMetrics(m *metrics.Metrics) ServerOption {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// WithHealth serves the report of the checker at /readyz.
+func WithHealth(checker *health.Checker) ServerOption {
+	return func(s *Server) {
+		s.health = checker
+	}
+}
+
+// WithDrainDelay sets how long the server reports not ready on shutdown
+// before it stops accepting requests, for load balancers to take it out of rotation.
+func WithDrainDelay(delay time.Duration) ServerOption {
+	return func(s *Server) {
+		s.drainDelay = delay
+	}
+}
+
+// WithCacheStats exposes the counters of the song cache.
+func WithCacheStats(cache *cache.SongRepository) ServerOption {
+	return func(s *Server) {
+		s.cache = cache
+	}
+}
+
+// WithBreakerStatus exposes the state of the external API circuit breaker.
+func WithBreakerStatus(breaker *rpc.CircuitBreaker) ServerOption {
+	return func(s *Server) {
+		s.breaker = breaker
+	}
+}
+
+func NewServer(db postgres.SongRepository, details rpc.SongDetailProvider, address string, opts ...ServerOption) *Server {
+	s := &Server{
+		db:         db,
+		songs:      service.NewSongService(db, details),
+		address:    address,
+		dateFormat: models.DateFormatLegacy,
+		stopping:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.server = &http.Server{Addr: address, Handler: s.Routes()}
+	s.server.RegisterOnShutdown(func() {
+		close(s.stopping)
+	})
+	return s
 }
 
 func (s *Server) Run() {
-	log.Printf("Starting server at %s\n", s.address)
-	if err := http.ListenAndServe(s.address, s.Routes()); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("HTTP server error: %v", err)
+	slog.Info("starting server", "address", s.address)
+	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("http server failed", logging.Err(err))
+		os.Exit(1)
 	}
 }
 
@@ -32,12 +194,18 @@ func (s *Server) Shutdown() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
+	if s.health != nil {
+		s.health.Drain()
+		slog.Info("draining", "delay", s.drainDelay)
+		time.Sleep(s.drainDelay)
+	}
+
 	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownRelease()
 
-	server := http.Server{Addr: s.address}
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("HTTP shutdown error: %v", err)
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("http shutdown failed", logging.Err(err))
+		os.Exit(1)
 	}
-	log.Println("Graceful shutdown complete.")
+	slog.Info("graceful shutdown complete")
 }
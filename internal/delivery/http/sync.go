@@ -0,0 +1,193 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"github.com/yankokirill/song-library/internal/resync"
+	"net/http"
+)
+
+// @Summary Start a song sync run
+// @Tags Admin
+// @Description Look up the details of the stale songs again right away instead of waiting for the schedule.
+// The run goes on in the background; its report is at /admin/sync/runs/{id}.
+// @Success 202 {object} models.SyncRun "Started run"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 409 {object} Problem "A run is already in progress"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/sync [post]
+func (s *Server) triggerSyncHandler(w http.ResponseWriter, r *http.Request) {
+	run, err := s.syncer.Trigger(r.Context())
+	if errors.Is(err, resync.ErrRunning) {
+		conflict(w, r, "A sync run is already in progress")
+		return
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/library/admin/sync/runs/%d", run.ID))
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// @Summary List song sync runs
+// @Tags Admin
+// @Param limit query int false "Maximum number of runs, newest first" default(20)
+// @Success 200 {object} []models.SyncRun
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/sync/runs [get]
+func (s *Server) listSyncRunsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r, 20)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+	runs, err := s.sync.ListRuns(r.Context(), limit)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// @Summary Get a song sync run
+// @Tags Admin
+// @Description Get the report of a run with the differences it found.
+// @Param id path int true "ID of the run"
+// @Success 200 {object} models.SyncRun
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Run not found"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/sync/runs/{id} [get]
+func (s *Server) getSyncRunHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+	run, err := s.sync.GetRun(r.Context(), int64(id))
+	if errors.Is(err, postgres.SyncRunNotFound) {
+		notFound(w, r, fmt.Sprintf("Sync run %d does not exist", id))
+		return
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// @Summary List song differences
+// @Tags Admin
+// @Description List the differences found by sync runs, e.g. those waiting for review.
+// @Param status query string false "Filter by status" Enums(pending, applied, rejected)
+// @Param limit query int false "Maximum number of differences, newest first" default(50)
+// @Success 200 {object} []models.SongDiff
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/sync/diffs [get]
+func (s *Server) listSongDiffsHandler(w http.ResponseWriter, r *http.Request) {
+	filter := models.DiffFilter{Status: models.DiffStatus(r.URL.Query().Get("status"))}
+	switch filter.Status {
+	case "", models.DiffPending, models.DiffApplied, models.DiffRejected:
+	default:
+		badRequest(w, r, invalidField("query", "status", "must be pending, applied or rejected"))
+		return
+	}
+	var err error
+	if filter.Limit, err = parseLimit(r, 50); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	diffs, err := s.sync.ListDiffs(r.Context(), filter)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, diffs)
+}
+
+// @Summary Apply a song difference
+// @Tags Admin
+// @Description Update the song with the value found upstream.
+// @Param id path int true "ID of the difference"
+// @Success 200 {object} models.SongDiff
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Difference not found"
+// @Failure 409 {object} Problem "Difference already resolved, or the song has changed since it was found"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/sync/diffs/{id}/apply [post]
+func (s *Server) applySongDiffHandler(w http.ResponseWriter, r *http.Request) {
+	s.resolveSongDiff(w, r, s.sync.ApplyDiff)
+}
+
+// @Summary Reject a song difference
+// @Tags Admin
+// @Description Keep the stored value of the song.
+// @Param id path int true "ID of the difference"
+// @Success 200 {object} models.SongDiff
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Difference not found"
+// @Failure 409 {object} Problem "Difference already resolved"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/sync/diffs/{id}/reject [post]
+func (s *Server) rejectSongDiffHandler(w http.ResponseWriter, r *http.Request) {
+	s.resolveSongDiff(w, r, s.sync.RejectDiff)
+}
+
+func (s *Server) resolveSongDiff(w http.ResponseWriter, r *http.Request,
+	resolve func(ctx context.Context, id int64) (*models.SongDiff, error)) {
+	id, err := parseID(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	diff, err := resolve(r.Context(), int64(id))
+	switch {
+	case errors.Is(err, postgres.DiffNotFound):
+		notFound(w, r, fmt.Sprintf("Song difference %d does not exist", id))
+	case errors.Is(err, postgres.DiffResolved):
+		conflict(w, r, fmt.Sprintf("Song difference %d is already resolved", id))
+	case errors.Is(err, postgres.DiffStale):
+		conflict(w, r, fmt.Sprintf("The song has changed since difference %d was found", id))
+	case err != nil:
+		internalError(w, r, err)
+	default:
+		writeJSON(w, http.StatusOK, diff)
+	}
+}
@@ -0,0 +1,264 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"github.com/yankokirill/song-library/internal/webhooks"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+var webhookEventTypes = []string{
+	models.EventSongCreated,
+	models.EventSongUpdated,
+	models.EventSongDeleted,
+}
+
+type WebhookCreateRequest struct {
+	URL    string   `json:"url" example:"https://example.com/hooks/songs"`
+	Events []string `json:"events" example:"song.created,song.deleted"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+func (req *WebhookCreateRequest) validate() error {
+	var errs validationErrors
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(invalidField("body", "url", "must be an absolute http(s) URL"))
+	}
+	if len(req.Events) == 0 {
+		errs.add(invalidField("body", "events", "must not be empty"))
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(webhookEventTypes, event) {
+			errs.add(invalidField("body", "events", fmt.Sprintf("unknown event type %q", event)))
+		}
+	}
+	return errs.err()
+}
+
+func parseDeliveryFilter(r *http.Request) (models.DeliveryFilter, error) {
+	filter := models.DeliveryFilter{
+		Status: models.DeliveryStatus(r.URL.Query().Get("status")),
+		Limit:  50,
+	}
+	switch filter.Status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead:
+	default:
+		return filter, invalidField("query", "status", "must be pending, succeeded or dead")
+	}
+
+	limit, err := parseLimit(r, filter.Limit)
+	if err != nil {
+		return filter, err
+	}
+	filter.Limit = limit
+	return filter, nil
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("error encoding response", logging.Err(err))
+	}
+}
+
+// @Summary Register a webhook
+// @Tags Webhooks
+// @Description Subscribe a URL to song events (song.created, song.updated, song.deleted).
+// Every delivery is a POST of the event as JSON, signed in the X-Webhook-Signature header
+// as "t=<unix time>,v1=<hex HMAC-SHA256 of '<unix time>.<body>' with the secret>".
+// If no secret is given, one is generated. The secret is only returned by this request.
+// Failed deliveries are retried with exponential backoff and then moved to the dead letters.
+// @Param webhook body WebhookCreateRequest true "URL and event types"
+// @Success 201 {object} models.Webhook
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks [post]
+func (s *Server) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req WebhookCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		invalidJSON(w, r, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	webhook := &models.Webhook{URL: req.URL, Events: req.Events, Secret: req.Secret}
+	if webhook.Secret == "" {
+		secret, err := webhooks.GenerateSecret()
+		if err != nil {
+			internalError(w, r, fmt.Errorf("error generating webhook secret: %w", err))
+			return
+		}
+		webhook.Secret = secret
+	}
+
+	if err := s.webhooks.CreateWebhook(r.Context(), webhook); err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, webhook)
+}
+
+// @Summary List webhooks
+// @Tags Webhooks
+// @Success 200 {object} []models.Webhook
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks [get]
+func (s *Server) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := s.webhooks.ListWebhooks(r.Context())
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// @Summary Delete a webhook
+// @Tags Webhooks
+// @Description Unsubscribe a webhook and drop its deliveries.
+// @Param id path int true "ID of the webhook"
+// @Success 204 "Webhook successfully deleted"
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Webhook not found"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/{id} [delete]
+func (s *Server) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if err := s.webhooks.DeleteWebhook(r.Context(), id); err != nil {
+		if err == postgres.WebhookNotFound {
+			notFound(w, r, fmt.Sprintf("Webhook %d does not exist", id))
+		} else {
+			internalError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get the delivery log of a webhook
+// @Tags Webhooks
+// @Description Get the latest deliveries of a webhook, newest first, each with the history of its attempts.
+// @Param id path int true "ID of the webhook"
+// @Param status query string false "Only deliveries with this status" Enums(pending, succeeded, dead)
+// @Param limit query int false "Maximum number of deliveries to retrieve" default(50)
+// @Success 200 {object} []models.WebhookDelivery
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/{id}/deliveries [get]
+func (s *Server) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+	filter, err := parseDeliveryFilter(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+	filter.WebhookID = id
+	s.writeDeliveries(w, r, filter)
+}
+
+// @Summary Get the dead letters
+// @Tags Webhooks
+// @Description Get the deliveries of all webhooks that ran out of attempts, newest first.
+// @Param limit query int false "Maximum number of deliveries to retrieve" default(50)
+// @Success 200 {object} []models.WebhookDelivery
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/dead-letters [get]
+func (s *Server) listDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseDeliveryFilter(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+	filter.Status = models.DeliveryDead
+	s.writeDeliveries(w, r, filter)
+}
+
+func (s *Server) writeDeliveries(w http.ResponseWriter, r *http.Request, filter models.DeliveryFilter) {
+	deliveries, err := s.webhooks.ListDeliveries(r.Context(), filter)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// @Summary Replay a webhook delivery
+// @Tags Webhooks
+// @Description Send a dead or succeeded delivery again, with a fresh set of attempts.
+// @Param id path int true "ID of the delivery"
+// @Success 202 "Delivery scheduled"
+// @Failure 400 {object} Problem "Invalid request"
+// @Failure 401 {object} Problem "Missing or invalid credentials"
+// @Failure 403 {object} Problem "Caller without the required scope"
+// @Failure 404 {object} Problem "Delivery not found"
+// @Failure 409 {object} Problem "Delivery is still pending"
+// @Failure 429 {object} Problem "Rate limit exceeded, see Retry-After"
+// @Failure 500 {object} Problem "Internal Server Error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks/deliveries/{id}/replay [post]
+func (s *Server) replayWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		badRequest(w, r, err)
+		return
+	}
+
+	if err := s.webhooks.ReplayDelivery(r.Context(), int64(id)); err != nil {
+		switch err {
+		case postgres.DeliveryNotFound:
+			notFound(w, r, fmt.Sprintf("Delivery %d does not exist", id))
+		case postgres.DeliveryPending:
+			conflict(w, r, fmt.Sprintf("Delivery %d is still pending", id))
+		default:
+			internalError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
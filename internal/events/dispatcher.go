@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"time"
+)
+
+// Sink receives published domain events. A sink may see the same event
+// more than once and must tolerate duplicates.
+type Sink interface {
+	Publish(ctx context.Context, event models.Event) error
+}
+
+type SinkFunc func(ctx context.Context, event models.Event) error
+
+func (f SinkFunc) Publish(ctx context.Context, event models.Event) error {
+	return f(ctx, event)
+}
+
+// LogSink writes every event to the log.
+type LogSink struct{}
+
+func (LogSink) Publish(ctx context.Context, event models.Event) error {
+	slog.InfoContext(ctx, "song event", "event_id", event.ID, "type", event.Type, "song_id", event.SongID,
+		"payload", event.Payload)
+	return nil
+}
+
+type Outbox interface {
+	ProcessEvents(ctx context.Context, limit, maxAttempts int, backoff func(attempts int) time.Duration,
+		publish func(context.Context, models.Event) error) (int, error)
+	DeletePublishedEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Dispatcher publishes the events of the outbox to the sinks, at least once and in order.
+// A failed event is retried after a delay that doubles with every attempt, from backoff
+// up to maxBackoff. An event that still fails after maxAttempts is dead-lettered, so that
+// it does not hold up the rest, and a sink gets sinkTimeout to take an event.
+type Dispatcher struct {
+	outbox      Outbox
+	sinks       []Sink
+	interval    time.Duration
+	batchSize   int
+	retention   time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	sinkTimeout time.Duration
+}
+
+func NewDispatcher(outbox Outbox, interval time.Duration, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{
+		outbox:      outbox,
+		sinks:       sinks,
+		interval:    interval,
+		batchSize:   100,
+		retention:   24 * time.Hour,
+		maxAttempts: 10,
+		backoff:     time.Second,
+		maxBackoff:  5 * time.Minute,
+		sinkTimeout: 10 * time.Second,
+	}
+}
+
+// Run dispatches events until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DispatchOnce(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("error dispatching events", logging.Err(err))
+			}
+		case <-cleanup.C:
+			if _, err := d.outbox.DeletePublishedEvents(ctx, time.Now().Add(-d.retention)); err != nil {
+				slog.Error("error cleaning up outbox", logging.Err(err))
+			}
+		}
+	}
+}
+
+// DispatchOnce publishes the pending events and returns how many were published.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := d.outbox.ProcessEvents(ctx, d.batchSize, d.maxAttempts, d.backoffAfter, d.publish)
+		total += n
+		if err != nil || n < d.batchSize {
+			return total, err
+		}
+	}
+}
+
+// backoffAfter returns how long to wait after the given number of failed attempts.
+func (d *Dispatcher) backoffAfter(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
+
+func (d *Dispatcher) publish(ctx context.Context, event models.Event) error {
+	var errs []error
+	for _, sink := range d.sinks {
+		if err := d.publishTo(ctx, sink, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		slog.WarnContext(ctx, "failed to publish event", "event_id", event.ID, "type", event.Type, logging.Err(err))
+		return fmt.Errorf("failed to publish event %d: %w", event.ID, err)
+	}
+	return nil
+}
+
+// publishTo bounds the time the sink takes, as the outbox stays locked meanwhile.
+func (d *Dispatcher) publishTo(ctx context.Context, sink Sink, event models.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, d.sinkTimeout)
+	defer cancel()
+	return sink.Publish(ctx, event)
+}
@@ -0,0 +1,173 @@
+package events
+
+import (
+	"context"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type ChangeLog interface {
+	ChangesAfter(ctx context.Context, after models.ChangePosition, limit int) ([]models.Event, error)
+	ChangePosition(ctx context.Context, id int64) (models.ChangePosition, error)
+	LatestChangePosition(ctx context.Context) (models.ChangePosition, error)
+	Listen(ctx context.Context, notify func()) error
+	DeleteChangesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Feed fans the song changes of the change log out to live subscribers.
+type Feed struct {
+	changes   ChangeLog
+	retention time.Duration
+
+	mu          sync.Mutex
+	last        models.ChangePosition
+	subscribers map[chan models.Event]struct{}
+	wakeup      chan struct{}
+}
+
+// pollInterval is how often the log is read without a notification, as changes
+// are held back while older transactions run, which may not log changes themselves.
+const pollInterval = time.Second
+
+// subscriberBuffer is the number of changes a subscriber may lag behind
+// before it is dropped and has to resume from its last event id.
+const subscriberBuffer = 64
+
+func NewFeed(changes ChangeLog, retention time.Duration) *Feed {
+	return &Feed{
+		changes:     changes,
+		retention:   retention,
+		subscribers: make(map[chan models.Event]struct{}),
+		wakeup:      make(chan struct{}, 1),
+	}
+}
+
+// Subscribe returns a channel of the changes that happen from now on.
+// The channel is closed if the subscriber falls behind or the feed stops.
+func (f *Feed) Subscribe() (<-chan models.Event, func()) {
+	ch := make(chan models.Event, subscriberBuffer)
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[ch]; ok {
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Position returns the position of the change with the given id, to resume a subscription.
+func (f *Feed) Position(ctx context.Context, id int64) (models.ChangePosition, error) {
+	return f.changes.ChangePosition(ctx, id)
+}
+
+// ChangesAfter returns the logged changes after the given position, to resume a subscription.
+func (f *Feed) ChangesAfter(ctx context.Context, after models.ChangePosition, limit int) ([]models.Event, error) {
+	return f.changes.ChangesAfter(ctx, after, limit)
+}
+
+// Run follows the change log until the context is cancelled.
+func (f *Feed) Run(ctx context.Context) {
+	defer f.closeSubscribers()
+
+	for ctx.Err() == nil {
+		last, err := f.changes.LatestChangePosition(ctx)
+		if err == nil {
+			f.last = last
+			break
+		}
+		slog.Error("error starting change feed", logging.Err(err))
+		sleep(ctx, 5*time.Second)
+	}
+
+	go f.listen(ctx)
+
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.wakeup:
+			f.poll(ctx)
+		case <-poll.C:
+			f.poll(ctx)
+		case <-cleanup.C:
+			if _, err := f.changes.DeleteChangesBefore(ctx, time.Now().Add(-f.retention)); err != nil {
+				slog.Error("error cleaning up change log", logging.Err(err))
+			}
+		}
+	}
+}
+
+func (f *Feed) listen(ctx context.Context) {
+	notify := func() {
+		select {
+		case f.wakeup <- struct{}{}:
+		default:
+		}
+	}
+	for ctx.Err() == nil {
+		if err := f.changes.Listen(ctx, notify); err != nil {
+			slog.Warn("change feed disconnected", logging.Err(err))
+			sleep(ctx, time.Second)
+		}
+	}
+}
+
+func (f *Feed) poll(ctx context.Context) {
+	for {
+		changes, err := f.changes.ChangesAfter(ctx, f.last, 100)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("error reading change log", logging.Err(err))
+			}
+			return
+		}
+		for _, change := range changes {
+			f.broadcast(change)
+			f.last = change.Position()
+		}
+		if len(changes) < 100 {
+			return
+		}
+	}
+}
+
+func (f *Feed) broadcast(change models.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subscribers {
+		select {
+		case ch <- change:
+		default:
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (f *Feed) closeSubscribers() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subscribers {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,155 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a readiness report and of its checks.
+const (
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusDraining = "draining"
+)
+
+// Check verifies that a dependency of the service is usable.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Status    string  `json:"status" example:"ok"`
+	LatencyMS float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report tells whether the service is ready to serve, and why not.
+type Report struct {
+	Status    string            `json:"status" example:"ok"`
+	Checks    map[string]Result `json:"checks,omitempty"`
+	CheckedAt time.Time         `json:"checkedAt"`
+}
+
+func (r *Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Checker runs the checks, all at once, and keeps their report for a while,
+// so that frequent probes do not load the dependencies.
+type Checker struct {
+	checks   []Check
+	ttl      time.Duration
+	timeout  time.Duration
+	draining atomic.Bool
+
+	mu      sync.Mutex
+	report  *Report
+	expires time.Time
+}
+
+type Option func(*Checker)
+
+// WithCacheTTL sets how long a report is served before the checks run again.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *Checker) {
+		c.ttl = ttl
+	}
+}
+
+// WithTimeout sets how long a check may take before it fails.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Checker) {
+		c.timeout = timeout
+	}
+}
+
+func NewChecker(checks []Check, opts ...Option) *Checker {
+	c := &Checker{
+		checks:  checks,
+		ttl:     2 * time.Second,
+		timeout: 2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Drain makes the service report not ready from now on, as it is shutting down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check returns the last report if it is fresh enough, or runs the checks.
+func (c *Checker) Check(ctx context.Context) *Report {
+	if c.draining.Load() {
+		return &Report{Status: StatusDraining, CheckedAt: time.Now()}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.report != nil && time.Now().Before(c.expires) {
+		return c.report
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks)), CheckedAt: time.Now()}
+	for i, check := range c.checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailed
+		}
+	}
+	c.report = report
+	c.expires = report.CheckedAt.Add(c.ttl)
+	return report
+}
+
+func run(ctx context.Context, check Check) Result {
+	start := time.Now()
+	err := check.Run(ctx)
+	result := Result{Status: StatusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Reachable checks that the server at url answers, with anything but a server error.
+func Reachable(client *http.Client, url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("answered %s", resp.Status)
+		}
+		return nil
+	}
+}
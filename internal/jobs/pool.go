@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Store is the part of the job repository the pool works with.
+type Store interface {
+	ClaimJobs(ctx context.Context, limit int, lease time.Duration) ([]models.Job, error)
+	CompleteJob(ctx context.Context, id string, songID int) error
+	FailJob(ctx context.Context, id string, reason string) error
+	ReleaseJob(ctx context.Context, id string, after time.Duration) error
+}
+
+// Handler does the work of a job and returns the id of the added song.
+type Handler func(ctx context.Context, job models.Job) (int, error)
+
+// TemporaryError is a failure of a job that may pass on its own,
+// such as an unavailable upstream.
+type TemporaryError struct {
+	Err error
+	// RetryAfter, if not zero, is how long to wait before trying again.
+	RetryAfter time.Duration
+}
+
+func (e *TemporaryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TemporaryError) Unwrap() error {
+	return e.Err
+}
+
+// Pool runs jobs on a fixed number of workers. A job whose worker stops
+// before recording the outcome, e.g. on restart, is picked up again once
+// its lease expires, and failed after maxAttempts such claims. A job that
+// fails with a TemporaryError is put back in the queue until its last attempt.
+type Pool struct {
+	store        Store
+	handle       Handler
+	workers      int
+	pollInterval time.Duration
+	lease        time.Duration
+	maxAttempts  int
+	retryDelay   time.Duration
+}
+
+type Option func(*Pool)
+
+func WithWorkers(n int) Option {
+	return func(p *Pool) {
+		p.workers = n
+	}
+}
+
+func WithPollInterval(interval time.Duration) Option {
+	return func(p *Pool) {
+		p.pollInterval = interval
+	}
+}
+
+// WithLease sets how long a claimed job is hidden from other workers.
+func WithLease(lease time.Duration) Option {
+	return func(p *Pool) {
+		p.lease = lease
+	}
+}
+
+func WithMaxAttempts(n int) Option {
+	return func(p *Pool) {
+		p.maxAttempts = n
+	}
+}
+
+// WithRetryDelay sets how long a temporarily failed job waits in the queue
+// when the failure does not tell.
+func WithRetryDelay(delay time.Duration) Option {
+	return func(p *Pool) {
+		p.retryDelay = delay
+	}
+}
+
+func NewPool(store Store, handle Handler, opts ...Option) *Pool {
+	p := &Pool{
+		store:        store,
+		handle:       handle,
+		workers:      4,
+		pollInterval: 500 * time.Millisecond,
+		lease:        time.Minute,
+		maxAttempts:  3,
+		retryDelay:   10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *Pool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range p.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := p.RunOnce(ctx)
+		if err != nil {
+			slog.Error("job pool failed", logging.Err(err))
+		}
+		if n > 0 && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and runs a single due job and returns the number of jobs run.
+func (p *Pool) RunOnce(ctx context.Context) (int, error) {
+	jobs, err := p.store.ClaimJobs(ctx, 1, p.lease)
+	if err != nil || len(jobs) == 0 {
+		return 0, err
+	}
+	job := jobs[0]
+
+	if job.Attempts > p.maxAttempts {
+		reason := fmt.Sprintf("abandoned after %d attempts", p.maxAttempts)
+		return 1, p.store.FailJob(ctx, job.ID, reason)
+	}
+
+	songID, err := p.handle(ctx, job)
+	if err == nil {
+		// The song is added: record it even if the pool is stopping meanwhile.
+		return 1, p.store.CompleteJob(context.WithoutCancel(ctx), job.ID, songID)
+	}
+	if ctx.Err() != nil {
+		// Interrupted by shutdown: leave the job to be claimed again.
+		return 1, nil
+	}
+
+	var temporary *TemporaryError
+	if errors.As(err, &temporary) && job.Attempts < p.maxAttempts {
+		after := temporary.RetryAfter
+		if after <= 0 {
+			after = p.retryDelay
+		}
+		return 1, p.store.ReleaseJob(ctx, job.ID, after)
+	}
+	return 1, p.store.FailJob(ctx, job.ID, err.Error())
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yankokirill/song-library/internal/logging"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "song_library"
+
+// Metrics holds the metrics of the service in a registry of its own,
+// so that tests can gather them without the global state of a default registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests    *prometheus.HistogramVec
+	externalCalls   *prometheus.CounterVec
+	externalLatency prometheus.Histogram
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to serve HTTP requests, by method, route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		externalCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "external_api_requests_total",
+			Help:      "Requests to the external song API, by outcome: ok, rate_limited or the cause of the failure.",
+		}, []string{"outcome"}),
+		externalLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "external_api_request_duration_seconds",
+			Help:      "Time to get an answer from the external song API.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.externalCalls,
+		m.externalLatency,
+	)
+	return m
+}
+
+// Registry returns the registry of the metrics.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a served HTTP request.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveCall records a request to the external song API; it is an rpc.CallObserver.
+func (m *Metrics) ObserveCall(outcome string, duration time.Duration) {
+	m.externalCalls.WithLabelValues(outcome).Inc()
+	m.externalLatency.Observe(duration.Seconds())
+}
+
+// PoolStater exposes the statistics of a connection pool.
+type PoolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+// RegisterPools exposes the statistics of the connection pools, by name.
+func (m *Metrics) RegisterPools(pools map[string]PoolStater) {
+	m.registry.MustRegister(&poolCollector{pools: pools})
+}
+
+// SongCounter counts the songs of the library by their source.
+type SongCounter interface {
+	CountSongs(ctx context.Context) (map[string]int, error)
+}
+
+// RegisterSongCounter exposes the number of songs in the library,
+// counted anew on every scrape.
+func (m *Metrics) RegisterSongCounter(counter SongCounter) {
+	m.registry.MustRegister(&songCollector{counter: counter})
+}
+
+var (
+	poolAcquired = prometheus.NewDesc(namespace+"_db_pool_acquired_connections",
+		"Connections in use.", []string{"pool"}, nil)
+	poolIdle = prometheus.NewDesc(namespace+"_db_pool_idle_connections",
+		"Idle connections.", []string{"pool"}, nil)
+	poolTotal = prometheus.NewDesc(namespace+"_db_pool_total_connections",
+		"Open connections.", []string{"pool"}, nil)
+	poolMax = prometheus.NewDesc(namespace+"_db_pool_max_connections",
+		"Maximum number of connections.", []string{"pool"}, nil)
+	poolAcquires = prometheus.NewDesc(namespace+"_db_pool_acquires_total",
+		"Connections acquired from the pool.", []string{"pool"}, nil)
+	poolEmptyAcquires = prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total",
+		"Acquires that had to wait for a connection.", []string{"pool"}, nil)
+	poolAcquireWait = prometheus.NewDesc(namespace+"_db_pool_acquire_wait_seconds_total",
+		"Time spent acquiring connections.", []string{"pool"}, nil)
+	songs = prometheus.NewDesc(namespace+"_songs",
+		"Songs in the library, by source.", []string{"source"}, nil)
+)
+
+type poolCollector struct {
+	pools map[string]PoolStater
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{poolAcquired, poolIdle, poolTotal, poolMax, poolAcquires, poolEmptyAcquires, poolAcquireWait} {
+		ch <- desc
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, pool := range c.pools {
+		stat := pool.Stat()
+		ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(stat.AcquiredConns()), name)
+		ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(stat.IdleConns()), name)
+		ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(stat.TotalConns()), name)
+		ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(stat.MaxConns()), name)
+		ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()), name)
+		ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), name)
+		ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds(), name)
+	}
+}
+
+type songCollector struct {
+	counter SongCounter
+}
+
+func (c *songCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- songs
+}
+
+func (c *songCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	counts, err := c.counter.CountSongs(ctx)
+	if err != nil {
+		slog.Error("error counting songs", logging.Err(err))
+		ch <- prometheus.NewInvalidMetric(songs, err)
+		return
+	}
+	for source, n := range counts {
+		ch <- prometheus.MustNewConstMetric(songs, prometheus.GaugeValue, float64(n), source)
+	}
+}
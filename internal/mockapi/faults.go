@@ -0,0 +1,214 @@
+package mockapi
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings are the faults that can be changed while the mock runs, through /faults.
+type Settings struct {
+	// LatencyMS delays every response.
+	LatencyMS int `json:"latencyMs"`
+	// ErrorRate is the share of requests, from 0 to 1, that fail with ErrorStatus.
+	ErrorRate   float64 `json:"errorRate"`
+	ErrorStatus int     `json:"errorStatus,omitempty"`
+	// RetryAfter is sent with every injected failure.
+	RetryAfter string      `json:"retryAfter,omitempty"`
+	Songs      []SongFault `json:"songs,omitempty"`
+}
+
+// SongFault makes the requests for one song misbehave.
+type SongFault struct {
+	SongInfo
+	// Status fails every request for the song with the status code.
+	Status int `json:"status,omitempty"`
+	// Malformed answers with a body that is not valid JSON.
+	Malformed bool `json:"malformed,omitempty"`
+	// LatencyMS delays the responses for the song, on top of the global latency.
+	LatencyMS int `json:"latencyMs,omitempty"`
+}
+
+func (s *Settings) validate() error {
+	if s.LatencyMS < 0 {
+		return errors.New("latencyMs must not be negative")
+	}
+	if s.ErrorRate < 0 || s.ErrorRate > 1 {
+		return errors.New("errorRate must be between 0 and 1")
+	}
+	if s.ErrorStatus != 0 && !isErrorStatus(s.ErrorStatus) {
+		return fmt.Errorf("invalid errorStatus %d", s.ErrorStatus)
+	}
+	for _, song := range s.Songs {
+		if song.Title == "" || song.Group == "" {
+			return errors.New("song faults need a title and a group")
+		}
+		if song.Status != 0 && !isErrorStatus(song.Status) {
+			return fmt.Errorf("invalid status %d for %q by %q", song.Status, song.Title, song.Group)
+		}
+		if song.LatencyMS < 0 {
+			return fmt.Errorf("latencyMs of %q by %q must not be negative", song.Title, song.Group)
+		}
+	}
+	return nil
+}
+
+func isErrorStatus(status int) bool {
+	return status >= 400 && status <= 599
+}
+
+// Faults makes the mock API misbehave on purpose.
+// It is safe for concurrent use and may be changed while the mock serves requests.
+type Faults struct {
+	mu          sync.Mutex
+	failures    int
+	status      int
+	settings    Settings
+	songs       map[SongInfo]SongFault
+	requests    int
+	inFlight    int
+	maxInFlight int
+}
+
+// FailNext makes the next n requests fail with the given status code.
+func (f *Faults) FailNext(n, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures, f.status = n, status
+}
+
+// SetRetryAfter sets the Retry-After header of the injected failures.
+func (f *Faults) SetRetryAfter(value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settings.RetryAfter = value
+}
+
+// SetDelay delays every response by d.
+func (f *Faults) SetDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settings.LatencyMS = int(d.Milliseconds())
+}
+
+// SetErrorRate fails the given share of requests, from 0 to 1, with the status code.
+func (f *Faults) SetErrorRate(rate float64, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settings.ErrorRate, f.settings.ErrorStatus = rate, status
+}
+
+// SetSongFault replaces the fault of a song; the zero fault removes it.
+func (f *Faults) SetSongFault(fault SongFault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.songs == nil {
+		f.songs = make(map[SongInfo]SongFault)
+	}
+	if fault == (SongFault{SongInfo: fault.SongInfo}) {
+		delete(f.songs, fault.SongInfo)
+		return
+	}
+	f.songs[fault.SongInfo] = fault
+}
+
+// Apply replaces the settings, song faults included.
+func (f *Faults) Apply(settings Settings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+
+	songs := make(map[SongInfo]SongFault, len(settings.Songs))
+	for _, song := range settings.Songs {
+		songs[song.SongInfo] = song
+	}
+	settings.Songs = nil
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settings, f.songs = settings, songs
+	return nil
+}
+
+// Settings returns the current settings.
+func (f *Faults) Settings() Settings {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	settings := f.settings
+	settings.Songs = make([]SongFault, 0, len(f.songs))
+	for _, song := range f.songs {
+		settings.Songs = append(settings.Songs, song)
+	}
+	slices.SortFunc(settings.Songs, func(a, b SongFault) int {
+		if c := strings.Compare(a.Group, b.Group); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Title, b.Title)
+	})
+	return settings
+}
+
+// Requests returns the number of requests received so far.
+func (f *Faults) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// MaxInFlight returns the largest number of requests handled at the same time.
+func (f *Faults) MaxInFlight() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.maxInFlight
+}
+
+type fault struct {
+	status     int
+	retryAfter string
+	delay      time.Duration
+	malformed  bool
+}
+
+// inject records the start of a request for the song and returns the fault to inject, if any.
+func (f *Faults) inject(song SongInfo) fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+	f.inFlight++
+	f.maxInFlight = max(f.maxInFlight, f.inFlight)
+
+	songFault := f.songs[song]
+	injected := fault{
+		delay:     time.Duration(f.settings.LatencyMS+songFault.LatencyMS) * time.Millisecond,
+		malformed: songFault.Malformed,
+	}
+	switch {
+	case f.failures > 0:
+		f.failures--
+		injected.status = f.status
+	case songFault.Status != 0:
+		injected.status = songFault.Status
+	case f.settings.ErrorRate > 0 && rand.Float64() < f.settings.ErrorRate:
+		injected.status = f.settings.ErrorStatus
+		if injected.status == 0 {
+			injected.status = http.StatusInternalServerError
+		}
+	}
+	if injected.status != 0 {
+		injected.retryAfter = f.settings.RetryAfter
+	}
+	return injected
+}
+
+func (f *Faults) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inFlight--
+}
@@ -0,0 +1,85 @@
+package mockapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// NewHandler serves the songs at /info, as the external API does,
+// and the settings of the faults at /faults (GET to read, PUT to replace).
+func NewHandler(songs map[SongInfo]Song, faults *Faults) http.Handler {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		songInfo := SongInfo{
+			Title: r.URL.Query().Get("song"),
+			Group: r.URL.Query().Get("group"),
+		}
+
+		fault := faults.inject(songInfo)
+		defer faults.done()
+		select {
+		case <-time.After(fault.delay):
+		case <-r.Context().Done():
+			return
+		}
+		if fault.status != 0 {
+			if fault.retryAfter != "" {
+				w.Header().Set("Retry-After", fault.retryAfter)
+			}
+			http.Error(w, http.StatusText(fault.status), fault.status)
+			return
+		}
+
+		if songInfo.Title == "" || songInfo.Group == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		song, ok := songs[songInfo]
+		if !ok {
+			http.Error(w, "Song Not Found", http.StatusNotFound)
+			return
+		}
+
+		if !song.Valid {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if fault.malformed {
+			_, _ = w.Write([]byte(`{"releaseDate": "`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(song.SongDetail)
+	})
+
+	handler.HandleFunc("GET /faults", func(w http.ResponseWriter, r *http.Request) {
+		writeSettings(w, faults.Settings())
+	})
+	handler.HandleFunc("PUT /faults", func(w http.ResponseWriter, r *http.Request) {
+		var settings Settings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		if err := faults.Apply(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.Info("faults updated", "settings", settings)
+		writeSettings(w, faults.Settings())
+	})
+
+	return handler
+}
+
+func writeSettings(w http.ResponseWriter, settings Settings) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		slog.Error("error encoding faults", "error", err)
+	}
+}
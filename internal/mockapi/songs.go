@@ -0,0 +1,51 @@
+// Package mockapi imitates the external music info API, for tests and local runs.
+package mockapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/models"
+	"os"
+)
+
+type SongInfo struct {
+	Title string `json:"title"`
+	Group string `json:"group"`
+}
+
+// Song is an entry of the data file. Songs that are not valid
+// are answered with 500, as the real API does for broken records.
+type Song struct {
+	SongInfo
+	models.SongDetail
+	Valid bool `json:"valid"`
+}
+
+// DefaultSongs is the data the tests run against.
+//
+//go:embed songs.json
+var DefaultSongs []byte
+
+// LoadSongs parses a JSON array of songs.
+func LoadSongs(data []byte) (map[SongInfo]Song, error) {
+	var songs []Song
+	if err := json.Unmarshal(data, &songs); err != nil {
+		return nil, fmt.Errorf("failed to parse songs: %w", err)
+	}
+
+	store := make(map[SongInfo]Song, len(songs))
+	for _, song := range songs {
+		store[song.SongInfo] = song
+	}
+	return store, nil
+}
+
+// ReadSongs loads the songs of a data file.
+func ReadSongs(path string) (map[SongInfo]Song, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read songs: %w", err)
+	}
+	return LoadSongs(data)
+}
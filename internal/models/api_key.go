@@ -0,0 +1,47 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// ScopeRead allows reading songs, lyrics, jobs and the change stream.
+	ScopeRead = "read"
+	// ScopeWrite allows adding, updating and deleting songs.
+	ScopeWrite = "write"
+	// ScopeAdmin allows managing webhooks, syncs, caches and API keys.
+	ScopeAdmin = "admin"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// APIKey identifies a client of the API. Only a hash of the key is stored;
+// the key itself is returned once, when it is issued.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// HasScope reports whether the key grants the scope.
+func (k *APIKey) HasScope(scope string) bool {
+	return ScopesGrant(k.Scopes, scope)
+}
+
+// ScopesGrant reports whether the granted scopes include the scope.
+// Admins may do everything, and writers may also read.
+func ScopesGrant(granted []string, scope string) bool {
+	switch {
+	case slices.Contains(granted, ScopeAdmin):
+		return true
+	case scope == ScopeRead && slices.Contains(granted, ScopeWrite):
+		return true
+	}
+	return slices.Contains(granted, scope)
+}
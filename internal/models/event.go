@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventSongCreated = "song.created"
+	EventSongUpdated = "song.updated"
+	EventSongDeleted = "song.deleted"
+)
+
+// Event is a domain event recorded for a change of a song.
+// The payload of every song event is the SongInfo of the song.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	SongID    int             `json:"songId"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+	// TxID is the transaction that logged a song change.
+	TxID int64 `json:"-"`
+}
+
+// ChangePosition orders the song change log by transaction, then by id.
+// Ids are taken when a change is logged, not when it commits, so they alone
+// would let a change committed late fall behind changes already read.
+// Transactions are read only once all older ones are over, so that every
+// change read later comes after the changes read so far.
+type ChangePosition struct {
+	TxID int64
+	ID   int64
+}
+
+func (p ChangePosition) Before(other ChangePosition) bool {
+	if p.TxID != other.TxID {
+		return p.TxID < other.TxID
+	}
+	return p.ID < other.ID
+}
+
+// Position returns the position of a song change in the log.
+func (e Event) Position() ChangePosition {
+	return ChangePosition{TxID: e.TxID, ID: e.ID}
+}
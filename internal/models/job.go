@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job tracks a song that is being added in the background.
+type Job struct {
+	ID        string    `json:"id" example:"5f0c6b1e-8f4a-4a36-9a53-7c1e0b6d2f4e"`
+	Status    JobStatus `json:"status" example:"pending"`
+	Title     string    `json:"song" example:"Supermassive Black Hole"`
+	Group     string    `json:"group" example:"Muse"`
+	SongID    int       `json:"songId,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -1,7 +1,9 @@
 package models
 
 type PaginationInfo struct {
-	PrevGroup string `json:"prevGroup"`
-	PrevSong  string `json:"prevSong"`
-	Limit     int    `json:"limit"`
+	PrevGroup    string      `json:"prevGroup"`
+	PrevSong     string      `json:"prevSong"`
+	Limit        int         `json:"limit"`
+	ReleasedFrom ReleaseDate `json:"releasedFrom"`
+	ReleasedTo   ReleaseDate `json:"releasedTo"`
 }
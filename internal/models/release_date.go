@@ -0,0 +1,158 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type DatePrecision string
+
+const (
+	PrecisionYear  DatePrecision = "year"
+	PrecisionMonth DatePrecision = "month"
+	PrecisionDay   DatePrecision = "day"
+)
+
+// ReleaseDate is a date known to a year, a month or a day.
+// Time always holds the first day of the period.
+type ReleaseDate struct {
+	Time      time.Time
+	Precision DatePrecision
+}
+
+type DateFormat string
+
+const (
+	// DateFormatLegacy renders dates as 16.07.2006, 07.2006 or 2006.
+	DateFormatLegacy DateFormat = "legacy"
+	// DateFormatISO renders dates as ISO 8601: 2006-07-16, 2006-07 or 2006.
+	DateFormatISO DateFormat = "iso"
+)
+
+func ParseDateFormat(s string) (DateFormat, error) {
+	switch f := DateFormat(strings.ToLower(s)); f {
+	case DateFormatLegacy, DateFormatISO:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown date format %q", s)
+}
+
+var releaseDateLayouts = map[DateFormat]map[DatePrecision]string{
+	DateFormatLegacy: {
+		PrecisionDay:   "02.01.2006",
+		PrecisionMonth: "01.2006",
+		PrecisionYear:  "2006",
+	},
+	DateFormatISO: {
+		PrecisionDay:   "2006-01-02",
+		PrecisionMonth: "2006-01",
+		PrecisionYear:  "2006",
+	},
+}
+
+// ParseReleaseDate accepts both ISO 8601 and legacy dates of any precision.
+func ParseReleaseDate(s string) (ReleaseDate, error) {
+	for _, layouts := range releaseDateLayouts {
+		for precision, layout := range layouts {
+			if len(s) != len(layout) {
+				continue
+			}
+			if t, err := time.Parse(layout, s); err == nil {
+				return ReleaseDate{Time: t, Precision: precision}, nil
+			}
+		}
+	}
+	return ReleaseDate{}, fmt.Errorf("invalid release date %q", s)
+}
+
+func NewReleaseDate(t time.Time, precision DatePrecision) (ReleaseDate, error) {
+	switch precision {
+	case PrecisionYear:
+		t = time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	case PrecisionMonth:
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case PrecisionDay:
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		return ReleaseDate{}, fmt.Errorf("invalid release date precision %q", precision)
+	}
+	return ReleaseDate{Time: t, Precision: precision}, nil
+}
+
+func (d ReleaseDate) IsZero() bool {
+	return d.Precision == ""
+}
+
+// End returns the first day after the period.
+func (d ReleaseDate) End() time.Time {
+	switch d.Precision {
+	case PrecisionYear:
+		return d.Time.AddDate(1, 0, 0)
+	case PrecisionMonth:
+		return d.Time.AddDate(0, 1, 0)
+	default:
+		return d.Time.AddDate(0, 0, 1)
+	}
+}
+
+// Compare orders dates by the start of their period, coarser precision first,
+// so that 2006 sorts before 01.2006 and 01.2006 before 01.01.2006.
+func (d ReleaseDate) Compare(other ReleaseDate) int {
+	if c := d.Time.Compare(other.Time); c != 0 {
+		return c
+	}
+	return d.precisionRank() - other.precisionRank()
+}
+
+func (d ReleaseDate) precisionRank() int {
+	switch d.Precision {
+	case PrecisionYear:
+		return 1
+	case PrecisionMonth:
+		return 2
+	case PrecisionDay:
+		return 3
+	}
+	return 0
+}
+
+// Equal reports whether the dates start on the same day with the same precision,
+// so that 2006 and 01.01.2006 differ.
+func (d ReleaseDate) Equal(other ReleaseDate) bool {
+	return d.Compare(other) == 0
+}
+
+func (d ReleaseDate) Format(format DateFormat) string {
+	layout, ok := releaseDateLayouts[format][d.Precision]
+	if !ok {
+		return ""
+	}
+	return d.Time.Format(layout)
+}
+
+func (d ReleaseDate) String() string {
+	return d.Format(DateFormatLegacy)
+}
+
+func (d ReleaseDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *ReleaseDate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = ReleaseDate{}
+		return nil
+	}
+	parsed, err := ParseReleaseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
@@ -1,14 +1,24 @@
 package models
 
 type SongInfo struct {
-	ID          int    `json:"id"`
-	Title       string `json:"song"`
-	Group       string `json:"group"`
-	ReleaseDate string `json:"releaseDate"`
-	Link        string `json:"link"`
+	ID          int         `json:"id"`
+	Title       string      `json:"song"`
+	Group       string      `json:"group"`
+	ReleaseDate ReleaseDate `json:"releaseDate" swaggertype:"string" example:"16.07.2006"`
+	Link        string      `json:"link"`
+	// Source names where the details of the song came from:
+	// a song detail provider, or SongSourceManual.
+	Source string `json:"source"`
 }
 
+// SongSourceManual marks songs whose details were supplied by the client.
+const SongSourceManual = "manual"
+
 type Song struct {
 	SongInfo
 	Lyrics string
+	// JobID, if set, is the background job adding the song. The job is
+	// completed along with the song, and a job whose song is already added
+	// gets that song back instead of a duplicate.
+	JobID string
 }
@@ -1,7 +1,46 @@
 package models
 
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
 type SongDetail struct {
 	ReleaseDate string `json:"releaseDate"`
 	Text        string `json:"text"`
 	Link        string `json:"link"`
+	// Source names the provider that supplied the details.
+	Source string `json:"-"`
+}
+
+// InvalidFieldError tells which field of the song details is invalid.
+type InvalidFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *InvalidFieldError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+// Validate checks that the details can be stored: the release date must parse,
+// the lyrics must not be empty and the link, if any, must be an http(s) URL.
+func (d *SongDetail) Validate() error {
+	if d.ReleaseDate == "" {
+		return &InvalidFieldError{"releaseDate", "is required"}
+	}
+	if _, err := ParseReleaseDate(d.ReleaseDate); err != nil {
+		return &InvalidFieldError{"releaseDate", err.Error()}
+	}
+	if strings.TrimSpace(d.Text) == "" {
+		return &InvalidFieldError{"text", "is required"}
+	}
+	if d.Link != "" {
+		u, err := url.Parse(d.Link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &InvalidFieldError{"link", fmt.Sprintf("%q is not an http(s) URL", d.Link)}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SongLookup is a cached answer of a song detail provider,
+// keyed by the normalized title and group.
+type SongLookup struct {
+	Title     string
+	Group     string
+	Found     bool
+	Detail    SongDetail
+	ExpiresAt time.Time
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Fields of a song that are re-synced from the song detail providers.
+const (
+	SyncFieldReleaseDate = "releaseDate"
+	SyncFieldLink        = "link"
+	SyncFieldLyrics      = "lyrics"
+)
+
+type DiffStatus string
+
+const (
+	// DiffPending marks a difference waiting for review.
+	DiffPending  DiffStatus = "pending"
+	DiffApplied  DiffStatus = "applied"
+	DiffRejected DiffStatus = "rejected"
+)
+
+// SongDiff is a difference between a stored song and its details upstream.
+type SongDiff struct {
+	ID         int64      `json:"id"`
+	RunID      int64      `json:"runId"`
+	SongID     int        `json:"songId"`
+	Field      string     `json:"field" example:"link"`
+	OldValue   string     `json:"oldValue"`
+	NewValue   string     `json:"newValue"`
+	Status     DiffStatus `json:"status" example:"pending"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+}
+
+type SyncRun struct {
+	ID         int64      `json:"id"`
+	Trigger    string     `json:"trigger" example:"manual"`
+	Checked    int        `json:"checked"`
+	Changed    int        `json:"changed"`
+	Failed     int        `json:"failed"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Diffs      []SongDiff `json:"diffs,omitempty"`
+}
+
+type DiffFilter struct {
+	Status DiffStatus
+	Limit  int
+}
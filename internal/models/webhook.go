@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead marks a delivery that ran out of attempts.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+type WebhookDelivery struct {
+	ID             int64          `json:"id"`
+	WebhookID      int            `json:"webhookId"`
+	EventID        int64          `json:"eventId"`
+	EventType      string         `json:"eventType"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"nextAttemptAt"`
+	LastStatusCode int            `json:"lastStatusCode,omitempty"`
+	LastError      string         `json:"lastError,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	// History lists the attempts of the delivery, oldest first, replays included.
+	History []DeliveryAttempt `json:"history"`
+
+	// Payload, URL and Secret are only filled for deliveries claimed for sending.
+	Payload json.RawMessage `json:"-"`
+	URL     string          `json:"-"`
+	Secret  string          `json:"-"`
+}
+
+// DeliveryAttempt is the outcome of sending a delivery once.
+type DeliveryAttempt struct {
+	DeliveryID int64 `json:"-"`
+	// Status is the status of the delivery after the attempt.
+	Status      DeliveryStatus `json:"status"`
+	StatusCode  int            `json:"statusCode,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	DurationMS  float64        `json:"durationMs"`
+	AttemptedAt time.Time      `json:"attemptedAt"`
+	// NextAttemptAt is when a pending delivery is tried again.
+	NextAttemptAt time.Time `json:"-"`
+}
+
+type DeliveryFilter struct {
+	WebhookID int
+	Status    DeliveryStatus
+	Limit     int
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is the budget of a client: up to Burst requests at once,
+// refilled at Rate requests per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// State is the bucket of a client as last stored.
+type State struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Decision tells whether a request may proceed, and what is left of the budget.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, when this one is not.
+	RetryAfter time.Duration
+}
+
+// Validate checks that the bucket refills, and does so before it is forgotten.
+func (l Limit) Validate() error {
+	if l.Rate <= 0 {
+		return fmt.Errorf("rate must be above 0, got %g", l.Rate)
+	}
+	if l.Burst < 1 {
+		return fmt.Errorf("burst must be at least 1, got %d", l.Burst)
+	}
+	if refill := l.duration(float64(l.Burst)); refill > IdleTimeout {
+		return fmt.Errorf("a burst of %d at %g per second takes %s to refill, more than the idle timeout of %s",
+			l.Burst, l.Rate, refill.Round(time.Second), IdleTimeout)
+	}
+	return nil
+}
+
+// Full returns the state of a client never seen before.
+func (l Limit) Full(now time.Time) State {
+	return State{Tokens: float64(l.Burst), Updated: now}
+}
+
+// Take refills the bucket up to now and takes a token from it, if there is one.
+// Unlike Bucket, a refused request does not go into debt.
+func (l Limit) Take(s State, now time.Time) (State, Decision) {
+	burst := float64(l.Burst)
+	elapsed := max(now.Sub(s.Updated).Seconds(), 0)
+	s = State{Tokens: min(burst, s.Tokens+elapsed*l.Rate), Updated: now}
+
+	d := Decision{Allowed: s.Tokens >= 1, Limit: l.Burst}
+	if d.Allowed {
+		s.Tokens--
+	} else {
+		d.RetryAfter = l.duration(1 - s.Tokens)
+	}
+	d.Remaining = int(math.Floor(s.Tokens))
+	d.Reset = l.duration(burst - s.Tokens)
+	return s, d
+}
+
+// duration returns the time to refill the tokens.
+func (l Limit) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.Rate * float64(time.Second)))
+}
+
+// Store keeps the buckets of the clients by key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// IdleTimeout is how long a bucket is kept after its last request.
+// Limits must refill within it, as a forgotten bucket starts full.
+const IdleTimeout = time.Hour
+
+// MemoryStore keeps the buckets of the clients of this instance.
+type MemoryStore struct {
+	mu         sync.Mutex
+	buckets    map[string]State
+	lastPruned time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]State), lastPruned: time.Now()}
+}
+
+func (m *MemoryStore) Take(_ context.Context, key string, limit Limit) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.prune(now)
+	state, ok := m.buckets[key]
+	if !ok {
+		state = limit.Full(now)
+	}
+	state, decision := limit.Take(state, now)
+	m.buckets[key] = state
+	return decision, nil
+}
+
+// prune forgets the buckets idle for longer than IdleTimeout, once in a while.
+func (m *MemoryStore) prune(now time.Time) {
+	if now.Sub(m.lastPruned) < IdleTimeout/10 {
+		return
+	}
+	m.lastPruned = now
+	for key, state := range m.buckets {
+		if now.Sub(state.Updated) > IdleTimeout {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of buckets kept.
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
@@ -0,0 +1,24 @@
+package ratelimit
+
+import "context"
+
+// Semaphore caps the number of concurrent holders.
+type Semaphore chan struct{}
+
+func NewSemaphore(n int) Semaphore {
+	return make(Semaphore, n)
+}
+
+// Acquire blocks until a slot is free or the context is done.
+func (s Semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s Semaphore) Release() {
+	<-s
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	songsPrefix  = "songs|"
+	groupPrefix  = "group|"
+	lyricsPrefix = "lyrics|"
+)
+
+// SongRepository caches the list pages and lyrics read through it.
+// Writes made through it, or through a SyncRepository over it, invalidate
+// the affected entries; writes made elsewhere become visible once the
+// entries expire.
+type SongRepository struct {
+	postgres.SongRepository
+	cache  *lru
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type Stats struct {
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Entries  int    `json:"entries"`
+	Capacity int    `json:"capacity"`
+}
+
+func NewSongRepository(next postgres.SongRepository, size int, ttl time.Duration) *SongRepository {
+	return &SongRepository{
+		SongRepository: next,
+		cache:          newLRU(size, ttl),
+	}
+}
+
+func (c *SongRepository) Stats() Stats {
+	return Stats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Entries:  c.cache.len(),
+		Capacity: c.cache.capacity,
+	}
+}
+
+func pageKey(hint *models.PaginationInfo) string {
+	return fmt.Sprintf("%q|%q|%d|%s|%s", hint.PrevSong, hint.PrevGroup, hint.Limit,
+		hint.ReleasedFrom.Format(models.DateFormatISO), hint.ReleasedTo.Format(models.DateFormatISO))
+}
+
+func lyricsKey(id int) string {
+	return fmt.Sprintf("%s%d|", lyricsPrefix, id)
+}
+
+func (c *SongRepository) GetSongsInfo(ctx context.Context, hint *models.PaginationInfo) ([]models.SongInfo, error) {
+	return cached(c, songsPrefix+pageKey(hint), func() ([]models.SongInfo, error) {
+		return c.SongRepository.GetSongsInfo(ctx, hint)
+	})
+}
+
+func (c *SongRepository) GetGroupSongsInfo(ctx context.Context, group string, hint *models.PaginationInfo) ([]models.SongInfo, error) {
+	key := fmt.Sprintf("%s%q|%s", groupPrefix, group, pageKey(hint))
+	return cached(c, key, func() ([]models.SongInfo, error) {
+		return c.SongRepository.GetGroupSongsInfo(ctx, group, hint)
+	})
+}
+
+func (c *SongRepository) GetSongLyrics(ctx context.Context, id, offset, limit int) (string, error) {
+	key := fmt.Sprintf("%s%d|%d", lyricsKey(id), offset, limit)
+	return cached(c, key, func() (string, error) {
+		return c.SongRepository.GetSongLyrics(ctx, id, offset, limit)
+	})
+}
+
+func (c *SongRepository) AddSong(ctx context.Context, song *models.Song) (int, error) {
+	defer c.cache.invalidate(songsPrefix, groupPrefix)
+	return c.SongRepository.AddSong(ctx, song)
+}
+
+func (c *SongRepository) UpdateSongInfo(ctx context.Context, song *models.SongInfo) error {
+	defer c.cache.invalidate(songsPrefix, groupPrefix)
+	return c.SongRepository.UpdateSongInfo(ctx, song)
+}
+
+func (c *SongRepository) DeleteSong(ctx context.Context, id int) error {
+	defer c.cache.invalidate(songsPrefix, groupPrefix, lyricsKey(id))
+	return c.SongRepository.DeleteSong(ctx, id)
+}
+
+func (c *SongRepository) Clear(ctx context.Context) error {
+	defer c.cache.invalidate("")
+	return c.SongRepository.Clear(ctx)
+}
+
+func cached[T any](c *SongRepository, key string, load func() (T, error)) (T, error) {
+	if value, ok := c.cache.get(key); ok {
+		c.hits.Add(1)
+		return value.(T), nil
+	}
+	c.misses.Add(1)
+
+	generation := c.cache.currentGeneration()
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	c.cache.put(key, value, generation)
+	return value, nil
+}
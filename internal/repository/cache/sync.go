@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"slices"
+)
+
+// SyncRepository invalidates the entries of songs when differences
+// found by a sync are applied to them.
+type SyncRepository struct {
+	postgres.SyncRepository
+	songs *SongRepository
+}
+
+func NewSyncRepository(next postgres.SyncRepository, songs *SongRepository) *SyncRepository {
+	return &SyncRepository{SyncRepository: next, songs: songs}
+}
+
+func (c *SyncRepository) RecordSongSync(ctx context.Context, songID int, diffs []models.SongDiff) error {
+	applied := slices.ContainsFunc(diffs, func(diff models.SongDiff) bool {
+		return diff.Status == models.DiffApplied
+	})
+	if applied {
+		defer c.songs.cache.invalidate(songsPrefix, groupPrefix, lyricsKey(songID))
+	}
+	return c.SyncRepository.RecordSongSync(ctx, songID, diffs)
+}
+
+func (c *SyncRepository) ApplyDiff(ctx context.Context, id int64) (*models.SongDiff, error) {
+	diff, err := c.SyncRepository.ApplyDiff(ctx, id)
+	if err == nil {
+		c.songs.cache.invalidate(songsPrefix, groupPrefix, lyricsKey(diff.SongID))
+	}
+	return diff, err
+}
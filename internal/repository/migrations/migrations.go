@@ -1,11 +1,15 @@
 package migrations
 
 import (
+	"errors"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"log"
+	"io/fs"
+	"log/slog"
+	"strings"
 )
 
 func Up(migrationsPath, databaseURL string) error {
@@ -13,11 +17,51 @@ func Up(migrationsPath, databaseURL string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create migration instance: %w", err)
 	}
+	m.Log = migrateLogger{}
 
 	if err = m.Up(); err != nil && err != migrate.ErrNoChange {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	log.Println("Database migrations applied successfully")
+	version, dirty, err := m.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return fmt.Errorf("failed to read migration version: %w", err)
+	}
+	slog.Info("database migrations applied", "version", version, "dirty", dirty)
 	return nil
 }
+
+// Latest returns the version of the last migration in migrationsPath,
+// which the database is at once Up has run.
+func Latest(migrationsPath string) (uint, error) {
+	src, err := source.Open(migrationsPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open migrations: %w", err)
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to read migrations: %w", err)
+		}
+		version = next
+	}
+}
+
+// migrateLogger passes the messages of the migrator to the default logger.
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...any) {
+	slog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)), "component", "migrate")
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type APIKeyRepository interface {
+	// CreateAPIKey stores the key by its hash and fills in its id and creation time.
+	CreateAPIKey(ctx context.Context, key *models.APIKey, hash []byte) error
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+
+	// FindAPIKey returns the active key with the hash. Its use is recorded
+	// in the background, at most once per lastUsedPrecision.
+	FindAPIKey(ctx context.Context, hash []byte) (*models.APIKey, error)
+}
+
+// lastUsedPrecision is how stale the last use of a key may be,
+// so that a busy key is not written on every request.
+const lastUsedPrecision = time.Minute
+
+var APIKeyNotFound = errors.New("api key not found")
+
+type apiKeyRepo struct {
+	pool *pgxpool.Pool
+	// touching holds the ids of the keys whose use is being recorded.
+	touching sync.Map
+}
+
+func NewAPIKeyRepository(pool *pgxpool.Pool) APIKeyRepository {
+	return &apiKeyRepo{pool: pool}
+}
+
+const apiKeyColumns = `id, name, prefix, scopes, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var k models.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	return k, err
+}
+
+func (kr *apiKeyRepo) CreateAPIKey(ctx context.Context, key *models.APIKey, hash []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO api_keys (name, prefix, key_hash, scopes) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+	err := kr.pool.QueryRow(ctx, query, key.Name, key.Prefix, hash, key.Scopes).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating api key: %w", err)
+	}
+	return nil
+}
+
+func (kr *apiKeyRepo) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := kr.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching api keys: %w", err)
+	}
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.APIKey, error) {
+		return scanAPIKey(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (kr *apiKeyRepo) RevokeAPIKey(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`
+	tag, err := kr.pool.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("error revoking api key %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return APIKeyNotFound
+	}
+	return nil
+}
+
+func (kr *apiKeyRepo) FindAPIKey(ctx context.Context, hash []byte) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+	key, err := scanAPIKey(kr.pool.QueryRow(ctx, query, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, APIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking up api key: %w", err)
+	}
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) >= lastUsedPrecision {
+		kr.touch(context.WithoutCancel(ctx), key.ID)
+	}
+	return &key, nil
+}
+
+// touch records the use of the key in the background, unless it is being recorded already.
+func (kr *apiKeyRepo) touch(ctx context.Context, id int) {
+	if _, busy := kr.touching.LoadOrStore(id, struct{}{}); busy {
+		return
+	}
+	go func() {
+		defer kr.touching.Delete(id)
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `UPDATE api_keys SET last_used_at = now()
+			WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - make_interval(secs => $2))`
+		if _, err := kr.pool.Exec(ctx, query, id, lastUsedPrecision.Seconds()); err != nil {
+			slog.ErrorContext(ctx, "error recording use of api key", "key_id", id, logging.Err(err))
+		}
+	}()
+}
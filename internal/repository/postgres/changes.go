@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/models"
+	"time"
+)
+
+// ChangeLogRepository reads the log of song changes written by database triggers,
+// so it sees the changes made through any instance.
+type ChangeLogRepository interface {
+	// ChangesAfter returns the changes after the position, in the order of
+	// models.ChangePosition, leaving out the transactions not over yet
+	// and those started after them.
+	ChangesAfter(ctx context.Context, after models.ChangePosition, limit int) ([]models.Event, error)
+	// ChangePosition returns the position of the change with the id, or the start
+	// of the log if the change is unknown, such as one past the retention.
+	ChangePosition(ctx context.Context, id int64) (models.ChangePosition, error)
+	// LatestChangePosition returns the position of the last change that can be read.
+	LatestChangePosition(ctx context.Context) (models.ChangePosition, error)
+	// Listen calls notify for every change notification until the context is done
+	// or the connection fails.
+	Listen(ctx context.Context, notify func()) error
+	DeleteChangesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type changeLogRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewChangeLogRepository(pool *pgxpool.Pool) ChangeLogRepository {
+	return &changeLogRepo{pool: pool}
+}
+
+// settled selects the changes of the transactions older than any still running:
+// they are over, and every transaction that logs changes from now on is newer.
+const settled = `txid < txid_snapshot_xmin(txid_current_snapshot())`
+
+func (cr *changeLogRepo) ChangesAfter(ctx context.Context, after models.ChangePosition, limit int) ([]models.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT id, event_type, song_id, payload, created_at, txid
+		FROM song_changes
+		WHERE (txid, id) > ($1, $2) AND ` + settled + `
+		ORDER BY txid, id
+		LIMIT $3`
+	rows, err := cr.pool.Query(ctx, query, after.TxID, after.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching song changes: %w", err)
+	}
+	changes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Event, error) {
+		var e models.Event
+		err := row.Scan(&e.ID, &e.Type, &e.SongID, &e.Payload, &e.CreatedAt, &e.TxID)
+		return e, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning song changes: %w", err)
+	}
+	return changes, nil
+}
+
+func (cr *changeLogRepo) ChangePosition(ctx context.Context, id int64) (models.ChangePosition, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	position := models.ChangePosition{ID: id}
+	err := cr.pool.QueryRow(ctx, `SELECT txid FROM song_changes WHERE id = $1`, id).Scan(&position.TxID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ChangePosition{}, nil
+	} else if err != nil {
+		return models.ChangePosition{}, fmt.Errorf("error fetching song change: %w", err)
+	}
+	return position, nil
+}
+
+func (cr *changeLogRepo) LatestChangePosition(ctx context.Context) (models.ChangePosition, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var position models.ChangePosition
+	query := `SELECT txid, id FROM song_changes WHERE ` + settled + ` ORDER BY txid DESC, id DESC LIMIT 1`
+	err := cr.pool.QueryRow(ctx, query).Scan(&position.TxID, &position.ID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return models.ChangePosition{}, fmt.Errorf("error fetching latest song change: %w", err)
+	}
+	return position, nil
+}
+
+func (cr *changeLogRepo) Listen(ctx context.Context, notify func()) error {
+	conn, err := cr.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer func() {
+		unlistenCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := conn.Exec(unlistenCtx, `UNLISTEN song_changes`); err != nil {
+			conn.Conn().Close(unlistenCtx)
+		}
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, `LISTEN song_changes`); err != nil {
+		return fmt.Errorf("error listening to song changes: %w", err)
+	}
+	// Changes made before LISTEN took effect are picked up by the caller from the log.
+	notify()
+
+	for {
+		if _, err := conn.Conn().WaitForNotification(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error waiting for song changes: %w", err)
+		}
+		notify()
+	}
+}
+
+func (cr *changeLogRepo) DeleteChangesBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := cr.pool.Exec(ctx, `DELETE FROM song_changes WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting song changes: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/models"
+	"time"
+)
+
+type JobRepository interface {
+	CreateJob(ctx context.Context, job *models.Job) error
+	GetJob(ctx context.Context, id string) (*models.Job, error)
+
+	// ClaimJobs hides due pending jobs from other workers for the lease,
+	// after which they are claimed again unless completed.
+	ClaimJobs(ctx context.Context, limit int, lease time.Duration) ([]models.Job, error)
+	CompleteJob(ctx context.Context, id string, songID int) error
+	FailJob(ctx context.Context, id string, reason string) error
+	// ReleaseJob puts a claimed job back in the queue, due after the given time.
+	ReleaseJob(ctx context.Context, id string, after time.Duration) error
+}
+
+var JobNotFound = errors.New("job not found")
+
+type jobRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewJobRepository(pool *pgxpool.Pool) JobRepository {
+	return &jobRepo{pool: pool}
+}
+
+const jobColumns = `id::text, status, song_name, group_name, COALESCE(song_id, 0), COALESCE(error, ''),
+	attempts, created_at, updated_at`
+
+func scanJob(row pgx.Row) (models.Job, error) {
+	var j models.Job
+	err := row.Scan(&j.ID, &j.Status, &j.Title, &j.Group, &j.SongID, &j.Error,
+		&j.Attempts, &j.CreatedAt, &j.UpdatedAt)
+	return j, err
+}
+
+func (jr *jobRepo) CreateJob(ctx context.Context, job *models.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO song_jobs (song_name, group_name) VALUES ($1, $2) RETURNING ` + jobColumns
+	created, err := scanJob(jr.pool.QueryRow(ctx, query, job.Title, job.Group))
+	if err != nil {
+		return fmt.Errorf("error creating job: %w", err)
+	}
+	*job = created
+	return nil
+}
+
+func (jr *jobRepo) GetJob(ctx context.Context, id string) (*models.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + jobColumns + ` FROM song_jobs WHERE id = $1`
+	job, err := scanJob(jr.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, JobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+func (jr *jobRepo) ClaimJobs(ctx context.Context, limit int, lease time.Duration) ([]models.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE song_jobs
+		SET next_attempt_at = now() + make_interval(secs => $2),
+		    attempts = attempts + 1,
+		    updated_at = now()
+		WHERE id IN (
+			SELECT id FROM song_jobs
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING ` + jobColumns
+	rows, err := jr.pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming jobs: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Job, error) {
+		return scanJob(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (jr *jobRepo) CompleteJob(ctx context.Context, id string, songID int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE song_jobs SET status = 'succeeded', song_id = $2, updated_at = now() WHERE id = $1`
+	if _, err := jr.pool.Exec(ctx, query, id, songID); err != nil {
+		return fmt.Errorf("error completing job %s: %w", id, err)
+	}
+	return nil
+}
+
+func (jr *jobRepo) FailJob(ctx context.Context, id string, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE song_jobs SET status = 'failed', error = $2, updated_at = now() WHERE id = $1`
+	if _, err := jr.pool.Exec(ctx, query, id, reason); err != nil {
+		return fmt.Errorf("error failing job %s: %w", id, err)
+	}
+	return nil
+}
+
+func (jr *jobRepo) ReleaseJob(ctx context.Context, id string, after time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE song_jobs SET next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+		WHERE id = $1`
+	if _, err := jr.pool.Exec(ctx, query, id, after.Seconds()); err != nil {
+		return fmt.Errorf("error releasing job %s: %w", id, err)
+	}
+	return nil
+}
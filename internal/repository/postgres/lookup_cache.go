@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type LookupCacheRepository interface {
+	// GetLookup returns nil if there is no unexpired entry for the song.
+	GetLookup(ctx context.Context, title, group string) (*models.SongLookup, error)
+	PutLookup(ctx context.Context, lookup *models.SongLookup) error
+	// PurgeLookups deletes the entries matching the title and group,
+	// with an empty value matching any, and returns their number.
+	PurgeLookups(ctx context.Context, title, group string) (int64, error)
+}
+
+// lookupPruneInterval is how often the expired entries are deleted.
+const lookupPruneInterval = 10 * time.Minute
+
+type lookupCacheRepo struct {
+	pool *pgxpool.Pool
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+func NewLookupCacheRepository(pool *pgxpool.Pool) LookupCacheRepository {
+	return &lookupCacheRepo{pool: pool, lastPruned: time.Now()}
+}
+
+func (lr *lookupCacheRepo) GetLookup(ctx context.Context, title, group string) (*models.SongLookup, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	lookup := models.SongLookup{Title: title, Group: group}
+	query := `SELECT found, release_date, lyrics, link, source, expires_at
+		FROM song_lookup_cache
+		WHERE title_key = $1 AND group_key = $2 AND expires_at > now()`
+	err := lr.pool.QueryRow(ctx, query, title, group).Scan(&lookup.Found,
+		&lookup.Detail.ReleaseDate, &lookup.Detail.Text, &lookup.Detail.Link, &lookup.Detail.Source,
+		&lookup.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching cached lookup: %w", err)
+	}
+	return &lookup, nil
+}
+
+func (lr *lookupCacheRepo) PutLookup(ctx context.Context, lookup *models.SongLookup) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	lr.prune(ctx)
+
+	query := `INSERT INTO song_lookup_cache
+			(title_key, group_key, found, release_date, lyrics, link, source, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (title_key, group_key) DO UPDATE
+		SET found = EXCLUDED.found, release_date = EXCLUDED.release_date, lyrics = EXCLUDED.lyrics,
+		    link = EXCLUDED.link, source = EXCLUDED.source,
+		    created_at = now(), expires_at = EXCLUDED.expires_at`
+	_, err := lr.pool.Exec(ctx, query, lookup.Title, lookup.Group, lookup.Found,
+		lookup.Detail.ReleaseDate, lookup.Detail.Text, lookup.Detail.Link, lookup.Detail.Source,
+		lookup.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("error caching lookup: %w", err)
+	}
+	return nil
+}
+
+// prune deletes the expired entries, once in a while.
+func (lr *lookupCacheRepo) prune(ctx context.Context) {
+	lr.mu.Lock()
+	due := time.Since(lr.lastPruned) >= lookupPruneInterval
+	if due {
+		lr.lastPruned = time.Now()
+	}
+	lr.mu.Unlock()
+	if !due {
+		return
+	}
+
+	query := `DELETE FROM song_lookup_cache WHERE expires_at < now()`
+	if _, err := lr.pool.Exec(ctx, query); err != nil {
+		slog.ErrorContext(ctx, "error pruning cached lookups", logging.Err(err))
+	}
+}
+
+func (lr *lookupCacheRepo) PurgeLookups(ctx context.Context, title, group string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM song_lookup_cache WHERE ($1 = '' OR title_key = $1) AND ($2 = '' OR group_key = $2)`
+	tag, err := lr.pool.Exec(ctx, query, title, group)
+	if err != nil {
+		return 0, fmt.Errorf("error purging cached lookups: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"time"
+)
+
+type OutboxRepository interface {
+	// ProcessEvents hands pending events to publish in the order they were recorded
+	// and marks them published. It stops at the first event that fails to publish,
+	// so the event is retried once the delay given by backoff for its attempts so far
+	// has passed, and the events after it wait meanwhile. An event that has failed
+	// maxAttempts times is dead-lettered instead, and the next events go on.
+	// Only one instance processes events at a time; the others process none.
+	ProcessEvents(ctx context.Context, limit, maxAttempts int, backoff func(attempts int) time.Duration,
+		publish func(context.Context, models.Event) error) (int, error)
+	DeletePublishedEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+type outboxRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewOutboxRepository(pool *pgxpool.Pool) OutboxRepository {
+	return &outboxRepo{pool: pool}
+}
+
+func (ob *outboxRepo) ProcessEvents(ctx context.Context, limit, maxAttempts int, backoff func(attempts int) time.Duration,
+	publish func(context.Context, models.Event) error) (int, error) {
+	conn, err := ob.pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	// Skipping the events taken by another consumer would publish the events
+	// after them first: wait for the other one instead. The lock belongs to the
+	// session rather than a transaction, so that no transaction stays open
+	// while the sinks take the events.
+	var locked bool
+	query := `SELECT pg_try_advisory_lock(hashtext('outbox_events'))`
+	if err := conn.QueryRow(ctx, query).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("error locking outbox: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+	defer unlockOutbox(ctx, conn)
+
+	query = `SELECT id, event_type, song_id, payload, created_at, attempts, next_attempt_at <= now()
+		FROM outbox_events
+		WHERE published_at IS NULL AND dead_lettered_at IS NULL
+		ORDER BY id
+		LIMIT $1`
+	rows, err := conn.Query(ctx, query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching outbox events: %w", err)
+	}
+	type pendingEvent struct {
+		models.Event
+		attempts int
+		due      bool
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pendingEvent, error) {
+		var e pendingEvent
+		err := row.Scan(&e.ID, &e.Type, &e.SongID, &e.Payload, &e.CreatedAt, &e.attempts, &e.due)
+		return e, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error scanning outbox events: %w", err)
+	}
+
+	published := 0
+	for _, event := range events {
+		if !event.due {
+			// A failed event is waiting out its backoff.
+			return published, nil
+		}
+
+		if publishErr := publish(ctx, event.Event); publishErr != nil {
+			attempts := event.attempts + 1
+			if attempts < maxAttempts {
+				query = `UPDATE outbox_events
+					SET attempts = attempts + 1, last_error = $2, next_attempt_at = now() + make_interval(secs => $3)
+					WHERE id = $1`
+				_, err := conn.Exec(ctx, query, event.ID, publishErr.Error(), backoff(attempts).Seconds())
+				if err != nil {
+					return published, fmt.Errorf("error recording failure of event %d: %w", event.ID, err)
+				}
+				return published, nil
+			}
+
+			query = `UPDATE outbox_events SET attempts = attempts + 1, last_error = $2, dead_lettered_at = now()
+				WHERE id = $1`
+			if _, err := conn.Exec(ctx, query, event.ID, publishErr.Error()); err != nil {
+				return published, fmt.Errorf("error dead-lettering event %d: %w", event.ID, err)
+			}
+			slog.ErrorContext(ctx, "event dead-lettered", "event_id", event.ID, "type", event.Type,
+				"attempts", attempts, logging.Err(publishErr))
+			continue
+		}
+
+		query = `UPDATE outbox_events SET attempts = attempts + 1, published_at = now() WHERE id = $1`
+		if _, err := conn.Exec(ctx, query, event.ID); err != nil {
+			return published, fmt.Errorf("error marking event %d published: %w", event.ID, err)
+		}
+		published++
+	}
+	return published, nil
+}
+
+// unlockOutbox releases the lock of ProcessEvents, even if ctx is done by now.
+func unlockOutbox(ctx context.Context, conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT pg_advisory_unlock(hashtext('outbox_events'))`
+	if _, err := conn.Exec(ctx, query); err != nil {
+		// A connection still holding the lock must not go back to the pool:
+		// closing it ends the session and the lock with it.
+		slog.ErrorContext(ctx, "error unlocking outbox", logging.Err(err))
+		_ = conn.Hijack().Close(ctx)
+	}
+}
+
+func (ob *outboxRepo) DeletePublishedEvents(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM outbox_events WHERE published_at < $1`
+	tag, err := ob.pool.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting published events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/ratelimit"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// RateLimitRepository keeps the rate limit buckets of the clients,
+// so that their limits hold across the instances of the service.
+type RateLimitRepository interface {
+	Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Decision, error)
+}
+
+type rateLimitRepo struct {
+	pool *pgxpool.Pool
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+func NewRateLimitRepository(pool *pgxpool.Pool) RateLimitRepository {
+	return &rateLimitRepo{pool: pool, lastPruned: time.Now()}
+}
+
+func (rr *rateLimitRepo) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Decision, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	rr.prune(ctx)
+
+	var decision ratelimit.Decision
+	err := pgx.BeginFunc(ctx, rr.pool, func(tx pgx.Tx) error {
+		// The bucket of a new client starts full.
+		query := `INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, now())
+			ON CONFLICT (key) DO NOTHING`
+		if _, err := tx.Exec(ctx, query, key, float64(limit.Burst)); err != nil {
+			return err
+		}
+
+		var state ratelimit.State
+		var now time.Time
+		query = `SELECT tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`
+		if err := tx.QueryRow(ctx, query, key).Scan(&state.Tokens, &state.Updated, &now); err != nil {
+			return err
+		}
+
+		state, decision = limit.Take(state, now)
+		query = `UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1`
+		_, err := tx.Exec(ctx, query, key, state.Tokens, state.Updated)
+		return err
+	})
+	if err != nil {
+		return decision, fmt.Errorf("error taking rate limit token of %s: %w", key, err)
+	}
+	return decision, nil
+}
+
+// prune deletes the buckets idle for longer than ratelimit.IdleTimeout, once in a while.
+func (rr *rateLimitRepo) prune(ctx context.Context) {
+	rr.mu.Lock()
+	due := time.Since(rr.lastPruned) >= ratelimit.IdleTimeout/10
+	if due {
+		rr.lastPruned = time.Now()
+	}
+	rr.mu.Unlock()
+	if !due {
+		return
+	}
+
+	query := `DELETE FROM rate_limit_buckets WHERE updated_at < now() - make_interval(secs => $1)`
+	if _, err := rr.pool.Exec(ctx, query, ratelimit.IdleTimeout.Seconds()); err != nil {
+		slog.ErrorContext(ctx, "error pruning rate limit buckets", logging.Err(err))
+	}
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/logging"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type sessionKey struct{}
+
+// WithSession marks the context with the id of the client session it serves,
+// so that reads following the session's own writes are not sent to a lagging replica.
+func WithSession(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, id)
+}
+
+func sessionFrom(ctx context.Context) string {
+	id, _ := ctx.Value(sessionKey{}).(string)
+	return id
+}
+
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	window   time.Duration
+
+	mu         sync.Mutex
+	lastWrites map[string]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newReplicaSet(urls []string, window, healthCheckInterval time.Duration) (*replicaSet, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	rs := &replicaSet{
+		window:     window,
+		lastWrites: make(map[string]time.Time),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for i, url := range urls {
+		pool, err := Connect(url)
+		if err != nil {
+			for _, r := range rs.replicas {
+				r.pool.Close()
+			}
+			return nil, fmt.Errorf("error connecting to replica #%d: %w", i+1, err)
+		}
+		rs.replicas = append(rs.replicas, &replica{pool: pool})
+	}
+
+	rs.checkHealth()
+	go rs.run(healthCheckInterval)
+	return rs, nil
+}
+
+// pick returns a healthy replica in round-robin order, or nil if the read
+// must be served by the primary database.
+func (rs *replicaSet) pick(ctx context.Context) *pgxpool.Pool {
+	if rs == nil || rs.wroteRecently(sessionFrom(ctx)) {
+		return nil
+	}
+
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := range n {
+		r := rs.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.pool
+		}
+	}
+	return nil
+}
+
+func (rs *replicaSet) recordWrite(ctx context.Context) {
+	session := sessionFrom(ctx)
+	if rs == nil || session == "" {
+		return
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.lastWrites[session] = time.Now()
+}
+
+func (rs *replicaSet) wroteRecently(session string) bool {
+	if session == "" {
+		return false
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	last, ok := rs.lastWrites[session]
+	return ok && time.Since(last) < rs.window
+}
+
+func (rs *replicaSet) run(interval time.Duration) {
+	defer close(rs.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.checkHealth()
+			rs.forgetOldWrites()
+		}
+	}
+}
+
+func (rs *replicaSet) checkHealth() {
+	var wg sync.WaitGroup
+	for i, r := range rs.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			err := r.pool.Ping(ctx)
+			if wasHealthy := r.healthy.Swap(err == nil); wasHealthy != (err == nil) {
+				if err != nil {
+					slog.Warn("replica is unhealthy", "replica", i+1, logging.Err(err))
+				} else {
+					slog.Info("replica is healthy", "replica", i+1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (rs *replicaSet) forgetOldWrites() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for session, last := range rs.lastWrites {
+		if time.Since(last) >= rs.window {
+			delete(rs.lastWrites, session)
+		}
+	}
+}
+
+func (rs *replicaSet) close() {
+	if rs == nil {
+		return
+	}
+	close(rs.stop)
+	<-rs.done
+	for _, r := range rs.replicas {
+		r.pool.Close()
+	}
+}
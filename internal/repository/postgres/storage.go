@@ -2,53 +2,128 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/tracing"
 	"strings"
 	"time"
 )
 
 type SongRepository interface {
-	GetSongsInfo(hint *models.PaginationInfo) ([]models.SongInfo, error)
-	GetGroupSongsInfo(group string, hint *models.PaginationInfo) ([]models.SongInfo, error)
-	GetSongLyrics(id, offset, limit int) (string, error)
-
-	AddSong(song *models.Song) (int, error)
-	UpdateSongInfo(song *models.SongInfo) error
-
-	DeleteSong(id int) error
-	Clear() error
-
+	GetSongsInfo(ctx context.Context, hint *models.PaginationInfo) ([]models.SongInfo, error)
+	GetGroupSongsInfo(ctx context.Context, group string, hint *models.PaginationInfo) ([]models.SongInfo, error)
+	GetSongLyrics(ctx context.Context, id, offset, limit int) (string, error)
+	// CountSongs returns the number of songs in the library by their source.
+	CountSongs(ctx context.Context) (map[string]int, error)
+
+	AddSong(ctx context.Context, song *models.Song) (int, error)
+	UpdateSongInfo(ctx context.Context, song *models.SongInfo) error
+
+	DeleteSong(ctx context.Context, id int) error
+	Clear(ctx context.Context) error
+
+	// Ping checks that the database can be reached.
+	Ping(ctx context.Context) error
+	// SchemaVersion returns the last migration applied to the database,
+	// and whether it failed halfway.
+	SchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+
+	// Close closes the pools of the replicas. The pool of the primary
+	// database is shared with the other repositories and left open.
 	Close()
 }
 
 type songRepo struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	replicas *replicaSet
 }
 
 var SongNotFound = errors.New("song not found")
 
-func NewSongRepository(databaseURL string) (SongRepository, error) {
+type RepositoryOption func(*options)
+
+type options struct {
+	replicaURLs          []string
+	readYourWritesWindow time.Duration
+	healthCheckInterval  time.Duration
+}
+
+// WithReplicas sends reads to the given read replicas.
+// Writes always go to the primary database.
+func WithReplicas(urls ...string) RepositoryOption {
+	return func(o *options) {
+		o.replicaURLs = append(o.replicaURLs, urls...)
+	}
+}
+
+// WithReadYourWritesWindow keeps the reads of a session on the primary database
+// for the given time after the session's own write. The writes are remembered
+// by this repository only, not shared with other instances of the service.
+func WithReadYourWritesWindow(window time.Duration) RepositoryOption {
+	return func(o *options) {
+		o.readYourWritesWindow = window
+	}
+}
+
+// WithHealthCheckInterval sets how often the replicas are pinged.
+func WithHealthCheckInterval(interval time.Duration) RepositoryOption {
+	return func(o *options) {
+		o.healthCheckInterval = interval
+	}
+}
+
+func NewSongRepository(pool *pgxpool.Pool, opts ...RepositoryOption) (SongRepository, error) {
+	o := options{
+		readYourWritesWindow: 5 * time.Second,
+		healthCheckInterval:  5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	replicas, err := newReplicaSet(o.replicaURLs, o.readYourWritesWindow, o.healthCheckInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &songRepo{pool: pool, replicas: replicas}, nil
+}
+
+// Connect opens a pool of connections to the database. A single pool of the
+// primary database is shared by all the repositories, so that together they
+// stay within its connection limit.
+func Connect(databaseURL string) (*pgxpool.Pool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	pool, err := pgxpool.New(ctx, databaseURL)
+	config, err := pgxpool.ParseConfig(databaseURL)
 	if err != nil {
 		return nil, err
 	}
+	config.ConnConfig.Tracer = tracing.QueryTracer{}
+	return pgxpool.NewWithConfig(ctx, config)
+}
 
-	return &songRepo{pool: pool}, nil
+// reader returns the pool to serve a read from.
+func (sr *songRepo) reader(ctx context.Context) *pgxpool.Pool {
+	if pool := sr.replicas.pick(ctx); pool != nil {
+		return pool
+	}
+	return sr.pool
 }
 
-func (sr *songRepo) GetSongsInfo(hint *models.PaginationInfo) ([]models.SongInfo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (sr *songRepo) GetSongsInfo(ctx context.Context, hint *models.PaginationInfo) ([]models.SongInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	query := `SELECT * FROM get_songs_info($1, $2, $3)`
-	rows, err := sr.pool.Query(ctx, query, hint.PrevSong, hint.PrevGroup, hint.Limit)
+	from, to := releasePeriodBounds(hint)
+	query := `SELECT * FROM get_songs_info($1, $2, $3, $4, $5)`
+	rows, err := sr.reader(ctx).Query(ctx, query, hint.PrevSong, hint.PrevGroup, hint.Limit, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching songs: %w", err)
 	}
@@ -56,12 +131,10 @@ func (sr *songRepo) GetSongsInfo(hint *models.PaginationInfo) ([]models.SongInfo
 
 	var songs []models.SongInfo
 	for rows.Next() {
-		var song models.SongInfo
-		var date time.Time
-		if err := rows.Scan(&song.ID, &song.Title, &song.Group, &date, &song.Link); err != nil {
+		song, err := scanSongInfo(rows)
+		if err != nil {
 			return nil, fmt.Errorf("error scanning row: %w", err)
 		}
-		song.ReleaseDate = date.Format("02.01.2006")
 		songs = append(songs, song)
 	}
 
@@ -72,12 +145,13 @@ func (sr *songRepo) GetSongsInfo(hint *models.PaginationInfo) ([]models.SongInfo
 	return songs, nil
 }
 
-func (sr *songRepo) GetGroupSongsInfo(group string, hint *models.PaginationInfo) ([]models.SongInfo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (sr *songRepo) GetGroupSongsInfo(ctx context.Context, group string, hint *models.PaginationInfo) ([]models.SongInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	query := `SELECT * FROM get_group_songs_info($1, $2, $3)`
-	rows, err := sr.pool.Query(ctx, query, group, hint.PrevSong, hint.Limit)
+	from, to := releasePeriodBounds(hint)
+	query := `SELECT * FROM get_group_songs_info($1, $2, $3, $4, $5)`
+	rows, err := sr.reader(ctx).Query(ctx, query, group, hint.PrevSong, hint.Limit, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching songs: %w", err)
 	}
@@ -85,12 +159,10 @@ func (sr *songRepo) GetGroupSongsInfo(group string, hint *models.PaginationInfo)
 
 	var songs []models.SongInfo
 	for rows.Next() {
-		var song models.SongInfo
-		var date time.Time
-		if err := rows.Scan(&song.ID, &song.Title, &song.Group, &date, &song.Link); err != nil {
+		song, err := scanSongInfo(rows)
+		if err != nil {
 			return nil, fmt.Errorf("error scanning row: %w", err)
 		}
-		song.ReleaseDate = date.Format("02.01.2006")
 		songs = append(songs, song)
 	}
 
@@ -101,12 +173,12 @@ func (sr *songRepo) GetGroupSongsInfo(group string, hint *models.PaginationInfo)
 	return songs, nil
 }
 
-func (sr *songRepo) GetSongLyrics(id, offset, limit int) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (sr *songRepo) GetSongLyrics(ctx context.Context, id, offset, limit int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	query := `SELECT * FROM get_song_verses($1, $2, $3)`
-	rows, err := sr.pool.Query(ctx, query, id, offset, limit)
+	rows, err := sr.reader(ctx).Query(ctx, query, id, offset, limit)
 	if err != nil {
 		return "", err
 	}
@@ -131,55 +203,180 @@ func (sr *songRepo) GetSongLyrics(id, offset, limit int) (string, error) {
 	return strings.Join(verses, "\n\n"), nil
 }
 
-func (sr *songRepo) AddSong(song *models.Song) (int, error) {
-	parsedDate, err := time.Parse("02.01.2006", song.ReleaseDate)
+func (sr *songRepo) CountSongs(ctx context.Context) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT source, count(*) FROM songs GROUP BY source`
+	rows, err := sr.reader(ctx).Query(ctx, query)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("error counting songs: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var source string
+		var n int
+		if err := rows.Scan(&source, &n); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		counts[source] = n
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", rows.Err())
+	}
+	return counts, nil
+}
+
+func (sr *songRepo) AddSong(ctx context.Context, song *models.Song) (int, error) {
+	if song.ReleaseDate.IsZero() {
+		return 0, fmt.Errorf("missing release date")
 	}
 	verses := strings.Split(song.Lyrics, "\n\n")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	sr.replicas.recordWrite(ctx)
 
 	var id int
-	query := "SELECT add_song($1, $2, $3, $4, $5)"
-	err = sr.pool.QueryRow(ctx, query, song.Title, song.Group, parsedDate, song.Link, verses).Scan(&id)
-	if err != nil {
+	err := pgx.BeginFunc(ctx, sr.pool, func(tx pgx.Tx) error {
+		if song.JobID != "" {
+			// A retried job may have added its song before failing to record it.
+			var added *int
+			query := `SELECT song_id FROM song_jobs WHERE id = $1 FOR UPDATE`
+			if err := tx.QueryRow(ctx, query, song.JobID).Scan(&added); err != nil {
+				return fmt.Errorf("error locking job %s: %w", song.JobID, err)
+			}
+			if added != nil {
+				id = *added
+				return nil
+			}
+		}
 
-	}
+		query := "SELECT add_song($1, $2, $3, $4, $5, $6, $7)"
+		err := tx.QueryRow(ctx, query,
+			song.Title,
+			song.Group,
+			song.ReleaseDate.Time,
+			string(song.ReleaseDate.Precision),
+			song.Link,
+			song.Source,
+			verses,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		query = `INSERT INTO song_sync_state (song_id) VALUES ($1)`
+		if _, err := tx.Exec(ctx, query, id); err != nil {
+			return err
+		}
+
+		if song.JobID != "" {
+			query = `UPDATE song_jobs SET status = 'succeeded', song_id = $2, updated_at = now() WHERE id = $1`
+			if _, err := tx.Exec(ctx, query, song.JobID, id); err != nil {
+				return fmt.Errorf("error completing job %s: %w", song.JobID, err)
+			}
+		}
+
+		info := song.SongInfo
+		info.ID = id
+		return appendEvent(ctx, tx, models.EventSongCreated, &info)
+	})
 	return id, err
 }
 
-func (sr *songRepo) UpdateSongInfo(song *models.SongInfo) error {
-	parsedDate, err := time.Parse("02.01.2006", song.ReleaseDate)
-	if err != nil {
-		parsedDate = time.Time{}
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (sr *songRepo) UpdateSongInfo(ctx context.Context, song *models.SongInfo) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	sr.replicas.recordWrite(ctx)
+
+	return pgx.BeginFunc(ctx, sr.pool, func(tx pgx.Tx) error {
+		query := `CALL update_song_info($1, $2, $3, $4, $5, $6)`
+		_, err := tx.Exec(ctx, query,
+			song.ID,
+			song.Title,
+			song.Group,
+			song.ReleaseDate.Time,
+			string(song.ReleaseDate.Precision),
+			song.Link,
+		)
+		if err != nil {
+			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "P0001" {
+				return SongNotFound
+			}
+			return err
+		}
 
-	query := `CALL update_song_info($1, $2, $3, $4, $5)`
-	_, err = sr.pool.Exec(ctx, query,
-		song.ID,
-		song.Title,
-		song.Group,
-		parsedDate,
-		song.Link,
-	)
-	if err != nil {
-		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "P0001" {
-			return SongNotFound
+		query = `SELECT id, song_name, group_name, release_date, release_date_precision, link, source
+			FROM songs WHERE id = $1`
+		updated, err := scanSongInfo(tx.QueryRow(ctx, query, song.ID))
+		if err != nil {
+			return fmt.Errorf("error fetching updated song: %w", err)
 		}
+		return appendEvent(ctx, tx, models.EventSongUpdated, &updated)
+	})
+}
+
+func scanSongInfo(row pgx.Row) (models.SongInfo, error) {
+	var song models.SongInfo
+	var date time.Time
+	var precision string
+	if err := row.Scan(&song.ID, &song.Title, &song.Group, &date, &precision, &song.Link, &song.Source); err != nil {
+		return song, err
 	}
-	return err
+
+	var err error
+	song.ReleaseDate, err = models.NewReleaseDate(date, models.DatePrecision(precision))
+	return song, err
 }
 
-func (sr *songRepo) DeleteSong(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// appendEvent records a song event in the outbox within the transaction of the change.
+func appendEvent(ctx context.Context, tx pgx.Tx, eventType string, song *models.SongInfo) error {
+	payload, err := json.Marshal(song)
+	if err != nil {
+		return fmt.Errorf("error encoding %s event: %w", eventType, err)
+	}
+
+	query := `INSERT INTO outbox_events (event_type, song_id, payload) VALUES ($1, $2, $3)`
+	if _, err := tx.Exec(ctx, query, eventType, song.ID, payload); err != nil {
+		return fmt.Errorf("error appending %s event: %w", eventType, err)
+	}
+	return nil
+}
 
-	query := `DELETE FROM songs WHERE id = $1`
-	_, err := sr.pool.Exec(ctx, query, id)
+// releasePeriodBounds converts the release date filter into a half-open
+// interval of dates, with nil standing for an unbounded side.
+func releasePeriodBounds(hint *models.PaginationInfo) (from, to *time.Time) {
+	if !hint.ReleasedFrom.IsZero() {
+		from = &hint.ReleasedFrom.Time
+	}
+	if !hint.ReleasedTo.IsZero() {
+		end := hint.ReleasedTo.End()
+		to = &end
+	}
+	return from, to
+}
+
+func (sr *songRepo) DeleteSong(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	sr.replicas.recordWrite(ctx)
+
+	err := pgx.BeginFunc(ctx, sr.pool, func(tx pgx.Tx) error {
+		query := `DELETE FROM songs WHERE id = $1
+			RETURNING id, song_name, group_name, release_date, release_date_precision, link, source`
+		deleted, err := scanSongInfo(tx.QueryRow(ctx, query, id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return appendEvent(ctx, tx, models.EventSongDeleted, &deleted)
+	})
 	if err != nil {
 		return fmt.Errorf("error deleting song with id %d: %w", id, err)
 	}
@@ -187,15 +384,36 @@ func (sr *songRepo) DeleteSong(id int) error {
 	return nil
 }
 
-func (sr *songRepo) Clear() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (sr *songRepo) Clear(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	sr.replicas.recordWrite(ctx)
 
 	query := `TRUNCATE TABLE songs RESTART IDENTITY CASCADE;`
+	if _, err := sr.pool.Exec(ctx, query); err != nil {
+		return err
+	}
+
+	// Event ids keep growing, so that consumers never mistake new events for seen ones.
+	query = `TRUNCATE TABLE outbox_events, song_changes;`
 	_, err := sr.pool.Exec(ctx, query)
 	return err
 }
 
+func (sr *songRepo) Ping(ctx context.Context) error {
+	return sr.pool.Ping(ctx)
+}
+
+func (sr *songRepo) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	if err := sr.pool.QueryRow(ctx, query).Scan(&version, &dirty); err != nil {
+		return 0, false, fmt.Errorf("error reading schema version: %w", err)
+	}
+	return uint(version), dirty, nil
+}
+
 func (sr *songRepo) Close() {
-	sr.pool.Close()
+	sr.replicas.close()
 }
@@ -0,0 +1,372 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/models"
+	"strings"
+	"time"
+)
+
+type SyncRepository interface {
+	// StaleSongs returns the songs, with lyrics, last synced before the given time, oldest first.
+	// Songs added manually have no upstream to sync with and are never returned.
+	StaleSongs(ctx context.Context, before time.Time, limit int) ([]models.Song, error)
+
+	// RejectedDiffs returns the differences of the song rejected on review.
+	RejectedDiffs(ctx context.Context, songID int) ([]models.SongDiff, error)
+
+	StartRun(ctx context.Context, trigger string) (*models.SyncRun, error)
+	// RecordSongSync marks the song as synced and stores its differences,
+	// applying those with the applied status to the song.
+	RecordSongSync(ctx context.Context, songID int, diffs []models.SongDiff) error
+	FinishRun(ctx context.Context, run *models.SyncRun) error
+
+	GetRun(ctx context.Context, id int64) (*models.SyncRun, error)
+	ListRuns(ctx context.Context, limit int) ([]models.SyncRun, error)
+
+	ListDiffs(ctx context.Context, filter models.DiffFilter) ([]models.SongDiff, error)
+	// ApplyDiff applies a pending difference to its song.
+	ApplyDiff(ctx context.Context, id int64) (*models.SongDiff, error)
+	RejectDiff(ctx context.Context, id int64) (*models.SongDiff, error)
+}
+
+var (
+	SyncRunNotFound = errors.New("sync run not found")
+	DiffNotFound    = errors.New("song difference not found")
+	DiffResolved    = errors.New("song difference is already resolved")
+	DiffStale       = errors.New("song has changed since the difference was found")
+)
+
+type syncRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewSyncRepository(pool *pgxpool.Pool) SyncRepository {
+	return &syncRepo{pool: pool}
+}
+
+func (sr *syncRepo) StaleSongs(ctx context.Context, before time.Time, limit int) ([]models.Song, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT s.id, s.song_name, s.group_name, s.release_date, s.release_date_precision, s.link, s.source,
+			COALESCE((SELECT string_agg(l.verse_text, E'\n\n' ORDER BY l.verse_number)
+			          FROM song_lyrics l WHERE l.song_id = s.id), '')
+		FROM songs s
+		LEFT JOIN song_sync_state st ON st.song_id = s.id
+		WHERE s.source <> 'manual' AND (st.synced_at IS NULL OR st.synced_at < $1)
+		ORDER BY st.synced_at NULLS FIRST, s.id
+		LIMIT $2`
+	rows, err := sr.pool.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching stale songs: %w", err)
+	}
+	songs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Song, error) {
+		var song models.Song
+		var date time.Time
+		var precision string
+		err := row.Scan(&song.ID, &song.Title, &song.Group, &date, &precision, &song.Link, &song.Source, &song.Lyrics)
+		if err != nil {
+			return song, err
+		}
+		song.ReleaseDate, err = models.NewReleaseDate(date, models.DatePrecision(precision))
+		return song, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning stale songs: %w", err)
+	}
+	return songs, nil
+}
+
+const runColumns = `id, trigger, checked, changed, failed, started_at, finished_at`
+
+func scanRun(row pgx.Row) (models.SyncRun, error) {
+	var r models.SyncRun
+	err := row.Scan(&r.ID, &r.Trigger, &r.Checked, &r.Changed, &r.Failed, &r.StartedAt, &r.FinishedAt)
+	return r, err
+}
+
+func (sr *syncRepo) StartRun(ctx context.Context, trigger string) (*models.SyncRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO song_sync_runs (trigger) VALUES ($1) RETURNING ` + runColumns
+	run, err := scanRun(sr.pool.QueryRow(ctx, query, trigger))
+	if err != nil {
+		return nil, fmt.Errorf("error starting sync run: %w", err)
+	}
+	return &run, nil
+}
+
+func (sr *syncRepo) RecordSongSync(ctx context.Context, songID int, diffs []models.SongDiff) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, sr.pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO song_sync_state (song_id) VALUES ($1)
+			ON CONFLICT (song_id) DO UPDATE SET synced_at = now()`
+		if _, err := tx.Exec(ctx, query, songID); err != nil {
+			return fmt.Errorf("error recording sync of song %d: %w", songID, err)
+		}
+
+		var applied []models.SongDiff
+		for _, diff := range diffs {
+			query := `INSERT INTO song_sync_diffs (run_id, song_id, field, old_value, new_value, status, resolved_at)
+				VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $6 = 'applied' THEN now() END)
+				ON CONFLICT (song_id, field) WHERE status = 'pending'
+				DO UPDATE SET run_id = EXCLUDED.run_id, old_value = EXCLUDED.old_value,
+				              new_value = EXCLUDED.new_value, created_at = now()`
+			_, err := tx.Exec(ctx, query,
+				diff.RunID, songID, diff.Field, diff.OldValue, diff.NewValue, string(diff.Status))
+			if err != nil {
+				return fmt.Errorf("error recording %s difference of song %d: %w", diff.Field, songID, err)
+			}
+			if diff.Status == models.DiffApplied {
+				applied = append(applied, diff)
+			}
+		}
+		return applyDiffs(ctx, tx, songID, applied)
+	})
+}
+
+// applyDiffs updates the song with the new values and records the update in the outbox.
+func applyDiffs(ctx context.Context, tx pgx.Tx, songID int, diffs []models.SongDiff) error {
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	// A difference found before the song was edited would undo the edit.
+	query := `SELECT release_date, release_date_precision, link,
+			COALESCE((SELECT string_agg(l.verse_text, E'\n\n' ORDER BY l.verse_number)
+			          FROM song_lyrics l WHERE l.song_id = s.id), '')
+		FROM songs s WHERE id = $1 FOR UPDATE`
+	var current models.Song
+	var currentDate time.Time
+	var currentPrecision string
+	err := tx.QueryRow(ctx, query, songID).Scan(&currentDate, &currentPrecision, &current.Link, &current.Lyrics)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SongNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching song %d: %w", songID, err)
+	}
+	current.ReleaseDate, err = models.NewReleaseDate(currentDate, models.DatePrecision(currentPrecision))
+	if err != nil {
+		return err
+	}
+	for _, diff := range diffs {
+		var value string
+		switch diff.Field {
+		case models.SyncFieldReleaseDate:
+			value = current.ReleaseDate.String()
+		case models.SyncFieldLink:
+			value = current.Link
+		case models.SyncFieldLyrics:
+			value = current.Lyrics
+		}
+		if value != diff.OldValue {
+			return DiffStale
+		}
+	}
+
+	var date *time.Time
+	var precision, link *string
+	for _, diff := range diffs {
+		switch diff.Field {
+		case models.SyncFieldReleaseDate:
+			releaseDate, err := models.ParseReleaseDate(diff.NewValue)
+			if err != nil {
+				return err
+			}
+			p := string(releaseDate.Precision)
+			date, precision = &releaseDate.Time, &p
+		case models.SyncFieldLink:
+			link = &diff.NewValue
+		case models.SyncFieldLyrics:
+			query = `DELETE FROM song_lyrics WHERE song_id = $1`
+			if _, err := tx.Exec(ctx, query, songID); err != nil {
+				return fmt.Errorf("error replacing lyrics of song %d: %w", songID, err)
+			}
+			query = `INSERT INTO song_lyrics (song_id, verse_number, verse_text)
+				SELECT $1, v.n, v.verse FROM unnest($2::TEXT[]) WITH ORDINALITY AS v(verse, n)`
+			if _, err := tx.Exec(ctx, query, songID, strings.Split(diff.NewValue, "\n\n")); err != nil {
+				return fmt.Errorf("error replacing lyrics of song %d: %w", songID, err)
+			}
+		}
+	}
+
+	var info models.SongInfo
+	if date == nil && link == nil {
+		// Only the lyrics changed: the lyrics trigger reports the change.
+		query = `SELECT id, song_name, group_name, release_date, release_date_precision, link, source
+			FROM songs WHERE id = $1`
+		info, err = scanSongInfo(tx.QueryRow(ctx, query, songID))
+	} else {
+		query = `UPDATE songs
+			SET release_date = COALESCE($2, release_date),
+			    release_date_precision = COALESCE($3, release_date_precision),
+			    link = COALESCE($4, link)
+			WHERE id = $1
+			RETURNING id, song_name, group_name, release_date, release_date_precision, link, source`
+		info, err = scanSongInfo(tx.QueryRow(ctx, query, songID, date, precision, link))
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SongNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error applying differences to song %d: %w", songID, err)
+	}
+	return appendEvent(ctx, tx, models.EventSongUpdated, &info)
+}
+
+func (sr *syncRepo) FinishRun(ctx context.Context, run *models.SyncRun) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE song_sync_runs SET checked = $2, changed = $3, failed = $4, finished_at = now()
+		WHERE id = $1 RETURNING finished_at`
+	if err := sr.pool.QueryRow(ctx, query, run.ID, run.Checked, run.Changed, run.Failed).Scan(&run.FinishedAt); err != nil {
+		return fmt.Errorf("error finishing sync run %d: %w", run.ID, err)
+	}
+	return nil
+}
+
+func (sr *syncRepo) GetRun(ctx context.Context, id int64) (*models.SyncRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + runColumns + ` FROM song_sync_runs WHERE id = $1`
+	run, err := scanRun(sr.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, SyncRunNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sync run %d: %w", id, err)
+	}
+
+	query = `SELECT ` + diffColumns + ` FROM song_sync_diffs WHERE run_id = $1 ORDER BY id`
+	rows, err := sr.pool.Query(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching differences of sync run %d: %w", id, err)
+	}
+	run.Diffs, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.SongDiff, error) {
+		return scanDiff(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning differences of sync run %d: %w", id, err)
+	}
+	return &run, nil
+}
+
+func (sr *syncRepo) ListRuns(ctx context.Context, limit int) ([]models.SyncRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + runColumns + ` FROM song_sync_runs ORDER BY id DESC LIMIT $1`
+	rows, err := sr.pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching sync runs: %w", err)
+	}
+	runs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.SyncRun, error) {
+		return scanRun(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning sync runs: %w", err)
+	}
+	return runs, nil
+}
+
+const diffColumns = `id, run_id, song_id, field, old_value, new_value, status, created_at, resolved_at`
+
+func scanDiff(row pgx.Row) (models.SongDiff, error) {
+	var d models.SongDiff
+	err := row.Scan(&d.ID, &d.RunID, &d.SongID, &d.Field, &d.OldValue, &d.NewValue, &d.Status,
+		&d.CreatedAt, &d.ResolvedAt)
+	return d, err
+}
+
+func (sr *syncRepo) RejectedDiffs(ctx context.Context, songID int) ([]models.SongDiff, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + diffColumns + ` FROM song_sync_diffs WHERE song_id = $1 AND status = 'rejected'`
+	rows, err := sr.pool.Query(ctx, query, songID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rejected differences of song %d: %w", songID, err)
+	}
+	diffs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.SongDiff, error) {
+		return scanDiff(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning rejected differences of song %d: %w", songID, err)
+	}
+	return diffs, nil
+}
+
+func (sr *syncRepo) ListDiffs(ctx context.Context, filter models.DiffFilter) ([]models.SongDiff, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + diffColumns + ` FROM song_sync_diffs
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY id DESC
+		LIMIT $2`
+	rows, err := sr.pool.Query(ctx, query, string(filter.Status), filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching song differences: %w", err)
+	}
+	diffs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.SongDiff, error) {
+		return scanDiff(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning song differences: %w", err)
+	}
+	return diffs, nil
+}
+
+func (sr *syncRepo) ApplyDiff(ctx context.Context, id int64) (*models.SongDiff, error) {
+	return sr.resolveDiff(ctx, id, models.DiffApplied)
+}
+
+func (sr *syncRepo) RejectDiff(ctx context.Context, id int64) (*models.SongDiff, error) {
+	return sr.resolveDiff(ctx, id, models.DiffRejected)
+}
+
+func (sr *syncRepo) resolveDiff(ctx context.Context, id int64, status models.DiffStatus) (*models.SongDiff, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var diff models.SongDiff
+	err := pgx.BeginFunc(ctx, sr.pool, func(tx pgx.Tx) error {
+		query := `SELECT ` + diffColumns + ` FROM song_sync_diffs WHERE id = $1 FOR UPDATE`
+		var err error
+		diff, err = scanDiff(tx.QueryRow(ctx, query, id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return DiffNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching song difference %d: %w", id, err)
+		}
+		if diff.Status != models.DiffPending {
+			return DiffResolved
+		}
+
+		query = `UPDATE song_sync_diffs SET status = $2, resolved_at = now() WHERE id = $1
+			RETURNING status, resolved_at`
+		if err := tx.QueryRow(ctx, query, id, string(status)).Scan(&diff.Status, &diff.ResolvedAt); err != nil {
+			return fmt.Errorf("error resolving song difference %d: %w", id, err)
+		}
+		if status == models.DiffApplied {
+			return applyDiffs(ctx, tx, diff.SongID, []models.SongDiff{diff})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
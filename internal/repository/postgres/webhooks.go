@@ -0,0 +1,266 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yankokirill/song-library/internal/models"
+	"time"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	ListWebhooks(ctx context.Context) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) error
+
+	ListDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error)
+	// ReplayDelivery schedules a finished delivery to be sent again right away.
+	ReplayDelivery(ctx context.Context, id int64) error
+
+	EnqueueDeliveries(ctx context.Context, event models.Event, body []byte) error
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, attempt models.DeliveryAttempt) error
+}
+
+var (
+	WebhookNotFound  = errors.New("webhook not found")
+	DeliveryNotFound = errors.New("webhook delivery not found")
+	DeliveryPending  = errors.New("webhook delivery is still pending")
+)
+
+type webhookRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookRepository(pool *pgxpool.Pool) WebhookRepository {
+	return &webhookRepo{pool: pool}
+}
+
+func (wr *webhookRepo) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhooks (url, event_types, secret) VALUES ($1, $2, $3) RETURNING id, created_at`
+	err := wr.pool.QueryRow(ctx, query, webhook.URL, webhook.Events, webhook.Secret).
+		Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating webhook: %w", err)
+	}
+	return nil
+}
+
+func (wr *webhookRepo) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT id, url, event_types, created_at FROM webhooks ORDER BY id`
+	rows, err := wr.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhooks: %w", err)
+	}
+	webhooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Webhook, error) {
+		var w models.Webhook
+		err := row.Scan(&w.ID, &w.URL, &w.Events, &w.CreatedAt)
+		return w, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (wr *webhookRepo) DeleteWebhook(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := wr.pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return WebhookNotFound
+	}
+	return nil
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.status, d.attempts, d.next_attempt_at,
+	COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), d.created_at, d.updated_at`
+
+func scanDelivery(row pgx.Row, extra ...any) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	dest := []any{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	return d, err
+}
+
+func (wr *webhookRepo) ListDeliveries(ctx context.Context, filter models.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries d
+		WHERE ($1 = 0 OR d.webhook_id = $1)
+		  AND ($2 = '' OR d.status = $2)
+		ORDER BY d.id DESC
+		LIMIT $3`
+	rows, err := wr.pool.Query(ctx, query, filter.WebhookID, string(filter.Status), filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook deliveries: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookDelivery, error) {
+		return scanDelivery(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning webhook deliveries: %w", err)
+	}
+	if err := wr.fillHistory(ctx, deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// fillHistory fills in the attempts of the deliveries.
+func (wr *webhookRepo) fillHistory(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	ids := make([]int64, len(deliveries))
+	index := make(map[int64]int, len(deliveries))
+	for i := range deliveries {
+		ids[i], index[deliveries[i].ID] = deliveries[i].ID, i
+		deliveries[i].History = []models.DeliveryAttempt{}
+	}
+
+	query := `SELECT delivery_id, status, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ANY($1)
+		ORDER BY id`
+	rows, err := wr.pool.Query(ctx, query, ids)
+	if err != nil {
+		return fmt.Errorf("error fetching webhook delivery attempts: %w", err)
+	}
+	attempts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.DeliveryAttempt, error) {
+		var a models.DeliveryAttempt
+		err := row.Scan(&a.DeliveryID, &a.Status, &a.StatusCode, &a.Error, &a.DurationMS, &a.AttemptedAt)
+		return a, err
+	})
+	if err != nil {
+		return fmt.Errorf("error scanning webhook delivery attempts: %w", err)
+	}
+	for _, attempt := range attempts {
+		d := &deliveries[index[attempt.DeliveryID]]
+		d.History = append(d.History, attempt)
+	}
+	return nil
+}
+
+func (wr *webhookRepo) ReplayDelivery(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, wr.pool, func(tx pgx.Tx) error {
+		var status models.DeliveryStatus
+		query := `SELECT status FROM webhook_deliveries WHERE id = $1 FOR UPDATE`
+		err := tx.QueryRow(ctx, query, id).Scan(&status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return DeliveryNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching webhook delivery %d: %w", id, err)
+		}
+		if status == models.DeliveryPending {
+			return DeliveryPending
+		}
+
+		query = `UPDATE webhook_deliveries
+			SET status = 'pending', attempts = 0, next_attempt_at = now(), updated_at = now()
+			WHERE id = $1`
+		if _, err := tx.Exec(ctx, query, id); err != nil {
+			return fmt.Errorf("error replaying webhook delivery %d: %w", id, err)
+		}
+		return nil
+	})
+}
+
+func (wr *webhookRepo) EnqueueDeliveries(ctx context.Context, event models.Event, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3 FROM webhooks WHERE $2 = ANY(event_types)
+		ON CONFLICT ON CONSTRAINT uq_webhook_event DO NOTHING`
+	if _, err := wr.pool.Exec(ctx, query, event.ID, event.Type, body); err != nil {
+		return fmt.Errorf("error enqueueing deliveries of event %d: %w", event.ID, err)
+	}
+	return nil
+}
+
+func (wr *webhookRepo) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id
+		  AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING ` + deliveryColumns + `, d.payload, w.url, w.secret`
+	rows, err := wr.pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookDelivery, error) {
+		var payload []byte
+		var url, secret string
+		d, err := scanDelivery(row, &payload, &url, &secret)
+		d.Payload, d.URL, d.Secret = payload, url, secret
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (wr *webhookRepo) RecordAttempt(ctx context.Context, attempt models.DeliveryAttempt) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, wr.pool, func(tx pgx.Tx) error {
+		query := `UPDATE webhook_deliveries
+			SET attempts = attempts + 1,
+			    status = $2,
+			    next_attempt_at = CASE WHEN $2 = 'pending' THEN $3 ELSE next_attempt_at END,
+			    last_status_code = NULLIF($4, 0),
+			    last_error = NULLIF($5, ''),
+			    updated_at = now()
+			WHERE id = $1`
+		_, err := tx.Exec(ctx, query,
+			attempt.DeliveryID,
+			string(attempt.Status),
+			attempt.NextAttemptAt,
+			attempt.StatusCode,
+			attempt.Error,
+		)
+		if err != nil {
+			return err
+		}
+
+		query = `INSERT INTO webhook_delivery_attempts (delivery_id, status, status_code, error, duration_ms, attempted_at)
+			VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), $5, $6)`
+		_, err = tx.Exec(ctx, query,
+			attempt.DeliveryID,
+			string(attempt.Status),
+			attempt.StatusCode,
+			attempt.Error,
+			attempt.DurationMS,
+			attempt.AttemptedAt,
+		)
+		return err
+	})
+}
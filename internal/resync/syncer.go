@@ -0,0 +1,233 @@
+package resync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+var ErrRunning = errors.New("a sync run is already in progress")
+
+// Store is the part of the sync repository the syncer works with.
+type Store interface {
+	StaleSongs(ctx context.Context, before time.Time, limit int) ([]models.Song, error)
+	RejectedDiffs(ctx context.Context, songID int) ([]models.SongDiff, error)
+	StartRun(ctx context.Context, trigger string) (*models.SyncRun, error)
+	RecordSongSync(ctx context.Context, songID int, diffs []models.SongDiff) error
+	FinishRun(ctx context.Context, run *models.SyncRun) error
+}
+
+// Syncer periodically looks up the details of songs synced longer than
+// maxAge ago and records how they differ from the stored ones. Differences
+// in the auto-apply fields are applied at once, the rest wait for review.
+// A difference already rejected on review is not recorded again, and a song
+// whose lookup fails is left stale, to be checked again on the next run.
+type Syncer struct {
+	store     Store
+	details   rpc.SongDetailProvider
+	interval  time.Duration
+	maxAge    time.Duration
+	batchSize int
+	autoApply []string
+	triggers  chan chan triggerResult
+}
+
+type triggerResult struct {
+	run *models.SyncRun
+	err error
+}
+
+type Option func(*Syncer)
+
+func WithInterval(interval time.Duration) Option {
+	return func(s *Syncer) {
+		s.interval = interval
+	}
+}
+
+// WithMaxAge sets how long ago a song must have been synced to be synced again.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(s *Syncer) {
+		s.maxAge = maxAge
+	}
+}
+
+// WithBatchSize limits the number of songs checked in a run.
+func WithBatchSize(n int) Option {
+	return func(s *Syncer) {
+		s.batchSize = n
+	}
+}
+
+// WithAutoApply applies the differences in the given fields without review.
+func WithAutoApply(fields ...string) Option {
+	return func(s *Syncer) {
+		s.autoApply = append(s.autoApply, fields...)
+	}
+}
+
+func NewSyncer(store Store, details rpc.SongDetailProvider, opts ...Option) *Syncer {
+	s := &Syncer{
+		store:     store,
+		details:   details,
+		interval:  time.Hour,
+		maxAge:    7 * 24 * time.Hour,
+		batchSize: 100,
+		triggers:  make(chan chan triggerResult),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.run(ctx, "scheduled", nil); err != nil {
+				slog.Error("song sync failed", "trigger", "scheduled", logging.Err(err))
+			}
+		case reply := <-s.triggers:
+			if _, err := s.run(ctx, "manual", reply); err != nil {
+				slog.Error("song sync failed", "trigger", "manual", logging.Err(err))
+			}
+		}
+	}
+}
+
+// Trigger asks Run to start a run right away and returns it as soon as it has started.
+func (s *Syncer) Trigger(ctx context.Context) (*models.SyncRun, error) {
+	reply := make(chan triggerResult, 1)
+	select {
+	case s.triggers <- reply:
+	default:
+		return nil, ErrRunning
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-reply:
+		return result.run, result.err
+	}
+}
+
+// RunOnce checks a batch of stale songs and returns the report of the run.
+func (s *Syncer) RunOnce(ctx context.Context, trigger string) (*models.SyncRun, error) {
+	return s.run(ctx, trigger, nil)
+}
+
+// run is RunOnce that, if started is not nil, also sends
+// the run to it once it has been recorded.
+func (s *Syncer) run(ctx context.Context, trigger string, started chan<- triggerResult) (*models.SyncRun, error) {
+	run, err := s.store.StartRun(ctx, trigger)
+	if started != nil {
+		var copied *models.SyncRun
+		if run != nil {
+			copied = new(models.SyncRun)
+			*copied = *run
+		}
+		started <- triggerResult{copied, err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A run that fails halfway is still finished, with what it got through.
+	songs, listErr := s.store.StaleSongs(ctx, time.Now().Add(-s.maxAge), s.batchSize)
+
+	for _, song := range songs {
+		if ctx.Err() != nil {
+			break
+		}
+		run.Checked++
+
+		diffs, err := s.diff(ctx, &song)
+		if err != nil {
+			run.Failed++
+			slog.WarnContext(ctx, "song sync failed", "song_id", song.ID, logging.Err(err))
+			continue
+		}
+		for i := range diffs {
+			diffs[i].RunID = run.ID
+		}
+		if err := s.store.RecordSongSync(ctx, song.ID, diffs); err != nil {
+			run.Failed++
+			slog.ErrorContext(ctx, "error recording song sync", "song_id", song.ID, logging.Err(err))
+			continue
+		}
+		if len(diffs) > 0 {
+			run.Changed++
+		}
+	}
+
+	// Record the outcome even when the run is cut short by shutdown.
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	return run, errors.Join(listErr, s.store.FinishRun(finishCtx, run))
+}
+
+// diff looks up the song and returns its differences with the stored one.
+func (s *Syncer) diff(ctx context.Context, song *models.Song) ([]models.SongDiff, error) {
+	songDetail, Err := s.details.GetSongDetail(ctx, song.Title, song.Group)
+	if Err != nil {
+		if Err.LogErr != nil {
+			return nil, Err.LogErr
+		}
+		return nil, errors.New(Err.Status)
+	}
+	releaseDate, err := models.ParseReleaseDate(songDetail.ReleaseDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid song detail from %s: %w", songDetail.Source, err)
+	}
+
+	var diffs []models.SongDiff
+	add := func(field, oldValue, newValue string) {
+		status := models.DiffPending
+		if slices.Contains(s.autoApply, field) {
+			status = models.DiffApplied
+		}
+		diffs = append(diffs, models.SongDiff{
+			SongID:   song.ID,
+			Field:    field,
+			OldValue: oldValue,
+			NewValue: newValue,
+			Status:   status,
+		})
+	}
+
+	if !releaseDate.Equal(song.ReleaseDate) {
+		add(models.SyncFieldReleaseDate, song.ReleaseDate.String(), releaseDate.String())
+	}
+	if songDetail.Link != song.Link {
+		add(models.SyncFieldLink, song.Link, songDetail.Link)
+	}
+	if songDetail.Text != song.Lyrics {
+		add(models.SyncFieldLyrics, song.Lyrics, songDetail.Text)
+	}
+	if len(diffs) == 0 {
+		return nil, nil
+	}
+
+	rejected, err := s.store.RejectedDiffs(ctx, song.ID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(diffs, func(diff models.SongDiff) bool {
+		return slices.ContainsFunc(rejected, func(r models.SongDiff) bool {
+			return r.Field == diff.Field && r.NewValue == diff.NewValue
+		})
+	}), nil
+}
@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+type BreakerStatus struct {
+	State               BreakerState `json:"state" example:"closed"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+	RetryAt             *time.Time   `json:"retryAt,omitempty"`
+}
+
+// CircuitBreaker opens after a run of consecutive failures and rejects calls
+// until the cooldown passes. Then it lets a single probe through: a success
+// closes the breaker, a failure opens it again. Calls allowed before the
+// breaker opened may end meanwhile; their outcome is ignored until it closes.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	// probe numbers the probes, so that only the current one ends the half-open state.
+	probe uint64
+}
+
+// BreakerToken identifies a call allowed by a CircuitBreaker.
+type BreakerToken struct {
+	probe uint64
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Record or Release with the returned token.
+func (b *CircuitBreaker) Allow() (BreakerToken, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return BreakerToken{}, false
+		}
+		b.state = BreakerHalfOpen
+		return b.startProbe(), true
+	case BreakerHalfOpen:
+		if b.probing {
+			return BreakerToken{}, false
+		}
+		return b.startProbe(), true
+	}
+	return BreakerToken{}, true
+}
+
+func (b *CircuitBreaker) startProbe() BreakerToken {
+	b.probing = true
+	b.probe++
+	return BreakerToken{probe: b.probe}
+}
+
+// isProbe reports whether the token is of the probe in progress.
+func (b *CircuitBreaker) isProbe(token BreakerToken) bool {
+	return b.probing && token.probe == b.probe
+}
+
+func (b *CircuitBreaker) Record(token BreakerToken, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.isProbe(token)
+	if b.state != BreakerClosed && !probe {
+		// A call allowed before the breaker opened.
+		return
+	}
+
+	b.probing = false
+	if !failed {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if probe || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release ends an allowed call whose outcome tells nothing of the health
+// of the upstream, such as one cancelled by the caller or rate limited.
+func (b *CircuitBreaker) Release(token BreakerToken) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.isProbe(token) {
+		b.probing = false
+	}
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state != BreakerClosed {
+		openedAt, retryAt := b.openedAt, b.openedAt.Add(b.cooldown)
+		status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+	}
+	return status
+}
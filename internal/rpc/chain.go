@@ -0,0 +1,44 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"strings"
+)
+
+// ChainProvider asks its providers in order and returns the first answer.
+// When every provider fails, the last failure is returned.
+type ChainProvider struct {
+	providers []SongDetailProvider
+}
+
+func NewChainProvider(providers ...SongDetailProvider) *ChainProvider {
+	return &ChainProvider{providers: providers}
+}
+
+func (p *ChainProvider) Name() string {
+	names := make([]string, len(p.providers))
+	for i, provider := range p.providers {
+		names[i] = provider.Name()
+	}
+	return "chain(" + strings.Join(names, ",") + ")"
+}
+
+func (p *ChainProvider) GetSongDetail(ctx context.Context, songTitle, groupName string) (*models.SongDetail, *HttpError) {
+	Err := InternalError(errors.New("no song detail providers configured"))
+	for _, provider := range p.providers {
+		songDetail, providerErr := provider.GetSongDetail(ctx, songTitle, groupName)
+		if providerErr == nil {
+			return songDetail, nil
+		}
+		slog.WarnContext(ctx, "song detail provider failed", "provider", provider.Name(), logging.Err(providerErr.LogErr))
+		Err = providerErr
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, Err
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/models"
+	"os"
+)
+
+type catalogueEntry struct {
+	Title string `json:"title"`
+	Group string `json:"group"`
+	models.SongDetail
+}
+
+type catalogueKey struct {
+	title string
+	group string
+}
+
+// FileProvider serves song details from a local JSON catalogue,
+// an array of objects with title, group, releaseDate, text and link.
+type FileProvider struct {
+	songs map[catalogueKey]models.SongDetail
+}
+
+func NewFileProvider(path string) (*FileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read song catalogue: %w", err)
+	}
+
+	var entries []catalogueEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse song catalogue %s: %w", path, err)
+	}
+
+	p := &FileProvider{songs: make(map[catalogueKey]models.SongDetail, len(entries))}
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid entry for %q by %q in song catalogue %s: %w", entry.Title, entry.Group, path, err)
+		}
+		p.songs[catalogueKey{entry.Title, entry.Group}] = entry.SongDetail
+	}
+	return p, nil
+}
+
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+func (p *FileProvider) GetSongDetail(_ context.Context, songTitle, groupName string) (*models.SongDetail, *HttpError) {
+	songDetail, ok := p.songs[catalogueKey{songTitle, groupName}]
+	if !ok {
+		return nil, songNotFound(fmt.Errorf("song %q by %q is not in the catalogue", songTitle, groupName))
+	}
+	songDetail.Source = p.Name()
+	return &songDetail, nil
+}
@@ -0,0 +1,213 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned for requests that have no fixture to replay.
+var ErrNotRecorded = errors.New("request was not recorded")
+
+// NotRecordedError names a request that has no fixture to replay.
+// It matches ErrNotRecorded.
+type NotRecordedError struct {
+	// Request is the method and the path with the sorted query of the request.
+	Request string
+}
+
+func (e *NotRecordedError) Error() string {
+	return ErrNotRecorded.Error() + ": " + e.Request
+}
+
+func (e *NotRecordedError) Is(target error) bool {
+	return target == ErrNotRecorded
+}
+
+// Fixture is a recorded request to the external API and its response.
+type Fixture struct {
+	Request struct {
+		Method string `json:"method"`
+		// URL is the path and the sorted query of the request.
+		URL string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int         `json:"statusCode"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       string      `json:"body"`
+	} `json:"response"`
+}
+
+// fixtureKey identifies a request regardless of the host it is sent to.
+func fixtureKey(req *http.Request) (method, url string) {
+	url = req.URL.Path
+	if query := req.URL.Query(); len(query) > 0 {
+		url += "?" + query.Encode()
+	}
+	return req.Method, url
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fixtureFile names the fixture after its query, with a hash to tell apart
+// requests that differ only in case or punctuation.
+func fixtureFile(method, url string) string {
+	sum := sha256.Sum256([]byte(method + " " + url))
+	slug := strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(url), "-"), "-")
+	if len(slug) > 80 {
+		slug = slug[:80]
+	}
+	return fmt.Sprintf("%s-%s.json", slug, hex.EncodeToString(sum[:4]))
+}
+
+// RecordingTransport sends requests on and writes every request with its
+// response to a fixture file in a directory, replacing earlier recordings.
+type RecordingTransport struct {
+	next http.RoundTripper
+	dir  string
+	mu   sync.Mutex
+}
+
+func NewRecordingTransport(next http.RoundTripper, dir string) (*RecordingTransport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	return &RecordingTransport{next: next, dir: dir}, nil
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(strings.NewReader(string(body)))
+
+	var fixture Fixture
+	fixture.Request.Method, fixture.Request.URL = fixtureKey(req)
+	fixture.Response.StatusCode = resp.StatusCode
+	fixture.Response.Header = resp.Header.Clone()
+	// Headers that change on every response would make fixtures differ for nothing.
+	fixture.Response.Header.Del("Date")
+	fixture.Response.Header.Del("Content-Length")
+	fixture.Response.Body = string(body)
+
+	if err := t.write(&fixture); err != nil {
+		slog.ErrorContext(req.Context(), "failed to record fixture", "method", fixture.Request.Method, "url", fixture.Request.URL, logging.Err(err))
+	}
+	return resp, nil
+}
+
+func (t *RecordingTransport) write(fixture *Fixture) error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(fixture); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	path := filepath.Join(t.dir, fixtureFile(fixture.Request.Method, fixture.Request.URL))
+	return os.WriteFile(path, data.Bytes(), 0o644)
+}
+
+// ReplayTransport answers requests from the fixtures of a directory, without
+// any network access. Requests without a fixture fail with ErrNotRecorded and
+// are kept, so that tests can report every one of them.
+type ReplayTransport struct {
+	fixtures map[string]*Fixture
+	mu       sync.Mutex
+	requests int
+	missed   []string
+}
+
+func NewReplayTransport(dir string) (*ReplayTransport, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s", dir)
+	}
+
+	t := &ReplayTransport{fixtures: make(map[string]*Fixture, len(paths))}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		fixture := &Fixture{}
+		if err := json.Unmarshal(data, fixture); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		t.fixtures[fixture.Request.Method+" "+fixture.Request.URL] = fixture
+	}
+	return t, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	method, url := fixtureKey(req)
+	key := method + " " + url
+
+	t.mu.Lock()
+	t.requests++
+	fixture, ok := t.fixtures[key]
+	if !ok {
+		t.missed = append(t.missed, key)
+	}
+	t.mu.Unlock()
+
+	if !ok {
+		slog.WarnContext(req.Context(), "request is not recorded", "request", key)
+		return nil, &NotRecordedError{Request: key}
+	}
+
+	status := fixture.Response.StatusCode
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        fixture.Response.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(fixture.Response.Body)),
+		ContentLength: int64(len(fixture.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Requests returns the number of requests replayed or missed so far.
+func (t *ReplayTransport) Requests() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.requests
+}
+
+// Missed returns the requests that had no fixture.
+func (t *ReplayTransport) Missed() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.missed...)
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LookupCache stores the answers of song detail providers.
+type LookupCache interface {
+	GetLookup(ctx context.Context, title, group string) (*models.SongLookup, error)
+	PutLookup(ctx context.Context, lookup *models.SongLookup) error
+}
+
+// CachingProvider remembers the details found by another provider for ttl
+// and the songs it did not find for negativeTTL. Other failures are not cached,
+// and a failing cache only costs a lookup.
+type CachingProvider struct {
+	next        SongDetailProvider
+	cache       LookupCache
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+func NewCachingProvider(next SongDetailProvider, cache LookupCache, ttl, negativeTTL time.Duration) *CachingProvider {
+	return &CachingProvider{
+		next:        next,
+		cache:       cache,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// NormalizeKey folds the case and whitespace of a title or group,
+// so that spellings differing only in those share a cache entry.
+func NormalizeKey(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func (p *CachingProvider) Name() string {
+	return p.next.Name()
+}
+
+func (p *CachingProvider) GetSongDetail(ctx context.Context, songTitle, groupName string) (*models.SongDetail, *HttpError) {
+	title, group := NormalizeKey(songTitle), NormalizeKey(groupName)
+
+	cached, err := p.cache.GetLookup(ctx, title, group)
+	if err != nil {
+		slog.WarnContext(ctx, "song lookup cache failed", logging.Err(err))
+	}
+	if cached != nil {
+		if !cached.Found {
+			return nil, notFound(songTitle, groupName)
+		}
+		return &cached.Detail, nil
+	}
+
+	songDetail, Err := p.next.GetSongDetail(ctx, songTitle, groupName)
+	lookup := &models.SongLookup{Title: title, Group: group}
+	switch {
+	case Err == nil:
+		lookup.Found, lookup.Detail = true, *songDetail
+		lookup.ExpiresAt = time.Now().Add(p.ttl)
+	case Err.StatusCode == http.StatusNotFound:
+		lookup.ExpiresAt = time.Now().Add(p.negativeTTL)
+	default:
+		return nil, Err
+	}
+
+	if err := p.cache.PutLookup(ctx, lookup); err != nil {
+		slog.WarnContext(ctx, "song lookup cache failed", logging.Err(err))
+	}
+	return songDetail, Err
+}
+
+func notFound(songTitle, groupName string) *HttpError {
+	return songNotFound(fmt.Errorf("song %q by %q was not found (cached)", songTitle, groupName))
+}
@@ -1,67 +1,428 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
 	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/ratelimit"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
 	"time"
 )
 
-var externalApiURL string
-
 type HttpError struct {
 	StatusCode int
 	Status     string
-	LogErr     error
+	// Cause tells clients why the request failed, as one of the Cause constants.
+	Cause  string
+	LogErr error
+	// RetryAfter, if not zero, is how long the caller should wait before trying again.
+	RetryAfter time.Duration
+}
+
+// Causes of failed song detail lookups.
+const (
+	CauseSongNotFound        = "song_not_found"
+	CauseUpstreamError       = "upstream_error"
+	CauseUpstreamTimeout     = "upstream_timeout"
+	CauseUpstreamUnavailable = "upstream_unavailable"
+	CauseInvalidResponse     = "invalid_upstream_response"
+	CauseInvalidRequest      = "invalid_request"
+	CauseInternal            = "internal_error"
+	CauseNotRecorded         = "upstream_not_recorded"
+)
+
+func songNotFound(err error) *HttpError {
+	return &HttpError{
+		StatusCode: http.StatusNotFound,
+		Status:     "Song Not Found",
+		Cause:      CauseSongNotFound,
+		LogErr:     err,
+	}
+}
+
+func badGateway(cause string, err error) *HttpError {
+	return &HttpError{
+		StatusCode: http.StatusBadGateway,
+		Status:     "Bad Gateway",
+		Cause:      cause,
+		LogErr:     err,
+	}
+}
+
+func gatewayTimeout(err error) *HttpError {
+	return &HttpError{
+		StatusCode: http.StatusGatewayTimeout,
+		Status:     "Gateway Timeout",
+		Cause:      CauseUpstreamTimeout,
+		LogErr:     err,
+	}
+}
+
+// notRecorded reports a request to the external API that has no fixture
+// to replay. It is a fault of the deployment, not of the upstream.
+func notRecorded(err *NotRecordedError) *HttpError {
+	return &HttpError{
+		StatusCode: http.StatusInternalServerError,
+		Status:     "No recorded external api response for " + err.Request,
+		Cause:      CauseNotRecorded,
+		LogErr:     err,
+	}
+}
+
+// InternalError reports a failure of our own.
+func InternalError(err error) *HttpError {
+	return &HttpError{
+		StatusCode: http.StatusInternalServerError,
+		Status:     "Internal Server Error",
+		Cause:      CauseInternal,
+		LogErr:     err,
+	}
+}
+
+// SongDetailProvider looks up the details of a song that is being added to the library.
+type SongDetailProvider interface {
+	// Name identifies the provider in responses and logs.
+	Name() string
+	GetSongDetail(ctx context.Context, songTitle, groupName string) (*models.SongDetail, *HttpError)
+}
+
+// HTTPProvider queries the external music info API. Timeouts, connection
+// errors and 502, 503 and 504 responses are retried with jittered exponential
+// backoff; other failures are returned at once.
+//
+// Failures of the API are reported as 502 Bad Gateway, or 504 Gateway Timeout
+// when it did not answer in time, and songs it does not know as 404. Responses
+// are validated, so that malformed details are reported as a failure of the API.
+//
+// When the API answers 429, no requests are sent until its Retry-After passes,
+// and callers get a 503 with the remaining wait instead.
+type HTTPProvider struct {
+	baseURL      string
+	client       *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	breaker      *CircuitBreaker
+	bucket       *ratelimit.Bucket
+	maxWait      time.Duration
+	concurrency  ratelimit.Semaphore
+	observer     CallObserver
+	mu           sync.Mutex
+	blockedUntil time.Time
+}
+
+type HTTPProviderOption func(*HTTPProvider)
+
+func WithTimeout(timeout time.Duration) HTTPProviderOption {
+	return func(p *HTTPProvider) {
+		p.client.Timeout = timeout
+	}
+}
+
+// WithTransport sends the requests through the transport,
+// such as a RecordingTransport or a ReplayTransport.
+func WithTransport(transport http.RoundTripper) HTTPProviderOption {
+	return func(p *HTTPProvider) {
+		p.client.Transport = transport
+	}
 }
 
-func SetExternalApiURL(url string) {
-	externalApiURL = url
+// WithMaxAttempts sets how many times a request is sent before giving up.
+func WithMaxAttempts(n int) HTTPProviderOption {
+	return func(p *HTTPProvider) {
+		p.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry, doubled after each
+// further retry up to max.
+func WithBackoff(base, max time.Duration) HTTPProviderOption {
+	return func(p *HTTPProvider) {
+		p.backoff = base
+		p.maxBackoff = max
+	}
 }
 
-func GetSongDetail(songTitle, groupName string) (*models.SongDetail, *HttpError) {
-	Err := &HttpError{StatusCode: http.StatusOK}
+// WithCircuitBreaker makes requests fail fast while the breaker is open.
+func WithCircuitBreaker(breaker *CircuitBreaker) HTTPProviderOption {
+	return func(p *HTTPProvider) {
+		p.breaker = breaker
+	}
+}
+
+// WithRateLimit takes a token from the bucket for every request sent. A caller
+// that would have to wait longer than maxWait for a token gets a 503 instead.
+func WithRateLimit(bucket *ratelimit.Bucket, maxWait time.Duration) HTTPProviderOption {
+	return func(p *HTTPProvider) {
+		p.bucket = bucket
+		p.maxWait = maxWait
+	}
+}
+
+// WithMaxConcurrency caps the number of requests in flight.
+func WithMaxConcurrency(n int) HTTPProviderOption {
+	return func(p *HTTPProvider) {
+		p.concurrency = ratelimit.NewSemaphore(n)
+	}
+}
+
+// CallObserver is told about every request sent to the API.
+type CallObserver interface {
+	// ObserveCall records how long the request took, and its outcome:
+	// "ok", "rate_limited" or the Cause of the failure.
+	ObserveCall(outcome string, duration time.Duration)
+}
+
+// WithCallObserver reports every request sent to the observer.
+func WithCallObserver(observer CallObserver) HTTPProviderOption {
+	return func(p *HTTPProvider) {
+		p.observer = observer
+	}
+}
 
+func NewHTTPProvider(baseURL string, opts ...HTTPProviderOption) *HTTPProvider {
+	p := &HTTPProvider{
+		baseURL:     baseURL,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		maxAttempts: 1,
+		backoff:     100 * time.Millisecond,
+		maxBackoff:  2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *HTTPProvider) Name() string {
+	return "external-api"
+}
+
+func (p *HTTPProvider) GetSongDetail(ctx context.Context, songTitle, groupName string) (*models.SongDetail, *HttpError) {
+	delay := p.backoff
+	for attempt := 1; ; attempt++ {
+		if Err := p.throttle(ctx); Err != nil {
+			return nil, Err
+		}
+		songDetail, Err, retryable := p.send(ctx, songTitle, groupName)
+		if Err != nil && Err.StatusCode == http.StatusTooManyRequests {
+			return nil, p.block(Err)
+		}
+		if Err == nil || !retryable || attempt >= p.maxAttempts {
+			return songDetail, Err
+		}
+
+		wait := delay/2 + rand.N(delay/2+1)
+		select {
+		case <-ctx.Done():
+			return nil, Err
+		case <-time.After(wait):
+		}
+		delay = min(2*delay, p.maxBackoff)
+	}
+}
+
+// send makes a single attempt within the concurrency cap and the circuit breaker.
+func (p *HTTPProvider) send(ctx context.Context, songTitle, groupName string) (*models.SongDetail, *HttpError, bool) {
+	if p.concurrency != nil {
+		if err := p.concurrency.Acquire(ctx); err != nil {
+			return nil, unavailable(fmt.Errorf("waiting for a request slot: %w", err), 0), false
+		}
+		defer p.concurrency.Release()
+	}
+
+	var token BreakerToken
+	if p.breaker != nil {
+		var allowed bool
+		if token, allowed = p.breaker.Allow(); !allowed {
+			return nil, unavailable(ErrCircuitOpen, 0), false
+		}
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "GET /info",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodGet, semconv.PeerService(p.Name())),
+	)
+	start := time.Now()
+	songDetail, Err, retryable := p.fetch(ctx, songTitle, groupName)
+	if p.observer != nil {
+		p.observer.ObserveCall(outcome(Err), time.Since(start))
+	}
+	endSpan(span, Err)
+	if p.breaker != nil {
+		if ctx.Err() != nil || (Err != nil && Err.StatusCode == http.StatusTooManyRequests) {
+			p.breaker.Release(token)
+		} else {
+			p.breaker.Record(token, retryable)
+		}
+	}
+	return songDetail, Err, retryable
+}
+
+const tracerName = "github.com/yankokirill/song-library/internal/rpc"
+
+// endSpan ends the span of a request, marking it failed unless the API answered.
+func endSpan(span trace.Span, Err *HttpError) {
+	span.SetAttributes(attribute.String("song_library.outcome", outcome(Err)))
+	if Err != nil && Err.Cause != CauseSongNotFound {
+		span.SetStatus(codes.Error, Err.LogErr.Error())
+	}
+	span.End()
+}
+
+func outcome(Err *HttpError) string {
+	switch {
+	case Err == nil:
+		return "ok"
+	case Err.StatusCode == http.StatusTooManyRequests:
+		return "rate_limited"
+	}
+	return Err.Cause
+}
+
+// throttle holds the request back while the API asked us to, and then
+// until the rate limit lets it through.
+func (p *HTTPProvider) throttle(ctx context.Context) *HttpError {
+	p.mu.Lock()
+	blocked := time.Until(p.blockedUntil)
+	p.mu.Unlock()
+	if blocked > 0 {
+		return unavailable(errors.New("external api asked to slow down"), blocked)
+	}
+
+	if p.bucket == nil {
+		return nil
+	}
+	wait, ok := p.bucket.Take(p.maxWait)
+	if !ok {
+		return unavailable(errors.New("external api rate limit exceeded"), wait)
+	}
+	if wait > 0 {
+		select {
+		case <-ctx.Done():
+			return unavailable(fmt.Errorf("waiting for the rate limit: %w", ctx.Err()), 0)
+		case <-time.After(wait):
+		}
+	}
+	return nil
+}
+
+// block stops requests for the time the 429 response asked for.
+func (p *HTTPProvider) block(Err *HttpError) *HttpError {
+	retryAfter := Err.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+
+	p.mu.Lock()
+	p.blockedUntil = time.Now().Add(retryAfter)
+	p.mu.Unlock()
+	return unavailable(Err.LogErr, retryAfter)
+}
+
+func unavailable(err error, retryAfter time.Duration) *HttpError {
+	return &HttpError{
+		StatusCode: http.StatusServiceUnavailable,
+		Status:     "Service Unavailable",
+		Cause:      CauseUpstreamUnavailable,
+		LogErr:     fmt.Errorf("external api: %w", err),
+		RetryAfter: retryAfter,
+	}
+}
+
+// fetch sends a single request. retryable reports a failure
+// of the upstream that may pass on its own.
+func (p *HTTPProvider) fetch(ctx context.Context, songTitle, groupName string) (*models.SongDetail, *HttpError, bool) {
 	query := url.Values{}
 	query.Set("song", songTitle)
 	query.Set("group", groupName)
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	reqURL := fmt.Sprintf("%s/info?%s", externalApiURL, query.Encode())
-	req, err := http.NewRequest("GET", reqURL, nil)
+	reqURL := fmt.Sprintf("%s/info?%s", p.baseURL, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
-		Err.Status = "Internal Server Error"
-		Err.StatusCode = http.StatusInternalServerError
-		Err.LogErr = fmt.Errorf("error preparing request to external api: %w", err)
-		return nil, Err
+		return nil, InternalError(fmt.Errorf("error preparing request to external api: %w", err)), false
 	}
 	req.Header.Set("Accept", "application/json")
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	trace.SpanFromContext(ctx).SetAttributes(semconv.URLFull(reqURL))
 
-	respRPC, err := client.Do(req)
+	respRPC, err := p.client.Do(req)
 	if err != nil {
-		Err.Status = "Internal Server Error"
-		Err.StatusCode = http.StatusInternalServerError
-		Err.LogErr = fmt.Errorf("error sending request to external api: %w", err)
-		return nil, Err
+		// A missing fixture will not be recorded by retrying.
+		var missing *NotRecordedError
+		if errors.As(err, &missing) {
+			return nil, notRecorded(missing), false
+		}
+		err = fmt.Errorf("error sending request to external api: %w", err)
+		retryable := ctx.Err() == nil
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, gatewayTimeout(err), retryable
+		}
+		return nil, badGateway(CauseUpstreamError, err), retryable
 	}
 	defer respRPC.Body.Close()
 
 	if respRPC.StatusCode != http.StatusOK {
-		Err.Status = respRPC.Status
-		Err.StatusCode = respRPC.StatusCode
-		Err.LogErr = fmt.Errorf("failed rpc.GetSongInfo(%s, %s), (status code: %d, status: %s)",
-			songTitle, groupName, Err.StatusCode, Err.Status)
-		return nil, Err
+		err := fmt.Errorf("failed rpc.GetSongInfo(%s, %s), (status code: %d, status: %s)",
+			songTitle, groupName, respRPC.StatusCode, respRPC.Status)
+		var Err *HttpError
+		switch respRPC.StatusCode {
+		case http.StatusNotFound:
+			return nil, songNotFound(err), false
+		case http.StatusTooManyRequests:
+			Err = &HttpError{StatusCode: respRPC.StatusCode, Status: respRPC.Status, LogErr: err}
+		case http.StatusGatewayTimeout:
+			Err = gatewayTimeout(err)
+		default:
+			Err = badGateway(CauseUpstreamError, err)
+		}
+		Err.RetryAfter = parseRetryAfter(respRPC.Header.Get("Retry-After"))
+		switch respRPC.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return nil, Err, true
+		}
+		return nil, Err, false
 	}
 
 	var songDetail models.SongDetail
 	if err := json.NewDecoder(respRPC.Body).Decode(&songDetail); err != nil {
-		Err.Status = "Internal Server Error"
-		Err.StatusCode = http.StatusInternalServerError
-		Err.LogErr = fmt.Errorf("error decoding json from external api: %w", err)
-		return nil, Err
+		return nil, badGateway(CauseInvalidResponse, fmt.Errorf("error decoding json from external api: %w", err)), false
+	}
+	if err := songDetail.Validate(); err != nil {
+		err = fmt.Errorf("invalid song detail of %q by %q from external api: %w", songTitle, groupName, err)
+		return nil, badGateway(CauseInvalidResponse, err), false
+	}
+	songDetail.Source = p.Name()
+	return &songDetail, nil, false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
 	}
-	return &songDetail, nil
+	return 0
 }
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/jobs"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"log/slog"
+	"net/http"
+)
+
+// SongService adds songs to the library, looking up their details
+// with a song detail provider.
+type SongService struct {
+	db      postgres.SongRepository
+	details rpc.SongDetailProvider
+}
+
+func NewSongService(db postgres.SongRepository, details rpc.SongDetailProvider) *SongService {
+	return &SongService{db: db, details: details}
+}
+
+// AddSong returns the stored song and the name of the provider that supplied its details.
+func (s *SongService) AddSong(ctx context.Context, title, group string) (*models.Song, string, *rpc.HttpError) {
+	return s.addSong(ctx, title, group, "")
+}
+
+func (s *SongService) addSong(ctx context.Context, title, group, jobID string) (*models.Song, string, *rpc.HttpError) {
+	songDetail, Err := s.details.GetSongDetail(ctx, title, group)
+	if Err != nil {
+		return nil, "", Err
+	}
+
+	song, err := newSong(title, group, songDetail)
+	if err != nil {
+		return nil, "", &rpc.HttpError{
+			StatusCode: http.StatusBadGateway,
+			Status:     "Bad Gateway",
+			Cause:      rpc.CauseInvalidResponse,
+			LogErr:     fmt.Errorf("invalid song detail from %s: %w", songDetail.Source, err),
+		}
+	}
+	song.JobID = jobID
+	if Err := s.store(ctx, song); Err != nil {
+		return nil, "", Err
+	}
+	return song, songDetail.Source, nil
+}
+
+// AddManualSong stores a song with details supplied by the client,
+// validated as strictly as those of the providers.
+func (s *SongService) AddManualSong(ctx context.Context, title, group string, detail *models.SongDetail) (*models.Song, *rpc.HttpError) {
+	manual := *detail
+	manual.Source = models.SongSourceManual
+	song, err := newSong(title, group, &manual)
+	if err != nil {
+		return nil, &rpc.HttpError{
+			StatusCode: http.StatusBadRequest,
+			Status:     err.Error(),
+			Cause:      rpc.CauseInvalidRequest,
+			LogErr:     fmt.Errorf("invalid manual song %q by %q: %w", title, group, err),
+		}
+	}
+	if Err := s.store(ctx, song); Err != nil {
+		return nil, Err
+	}
+	return song, nil
+}
+
+// newSong validates the details of a song.
+func newSong(title, group string, detail *models.SongDetail) (*models.Song, error) {
+	if err := detail.Validate(); err != nil {
+		return nil, err
+	}
+	releaseDate, err := models.ParseReleaseDate(detail.ReleaseDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Song{
+		SongInfo: models.SongInfo{
+			Title:       title,
+			Group:       group,
+			ReleaseDate: releaseDate,
+			Link:        detail.Link,
+			Source:      detail.Source,
+		},
+		Lyrics: detail.Text,
+	}, nil
+}
+
+func (s *SongService) store(ctx context.Context, song *models.Song) *rpc.HttpError {
+	id, err := s.db.AddSong(ctx, song)
+	if err != nil {
+		return rpc.InternalError(fmt.Errorf("failed to add song %w", err))
+	}
+	song.ID = id
+	return nil
+}
+
+// AddSongJob adds the song of a background job; it is a jobs.Handler.
+// An unavailable upstream fails the job temporarily. The song is stored
+// together with the outcome of the job, so a retried job adds it only once.
+func (s *SongService) AddSongJob(ctx context.Context, job models.Job) (int, error) {
+	song, _, Err := s.addSong(ctx, job.Title, job.Group, job.ID)
+	if Err != nil {
+		slog.WarnContext(ctx, "song job failed", "job_id", job.ID, "status", Err.StatusCode, logging.Err(Err.LogErr))
+		err := fmt.Errorf("%d %s", Err.StatusCode, Err.Status)
+		if Err.Cause == rpc.CauseUpstreamUnavailable {
+			return 0, &jobs.TemporaryError{Err: err, RetryAfter: Err.RetryAfter}
+		}
+		return 0, err
+	}
+	return song.ID, nil
+}
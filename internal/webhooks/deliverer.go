@@ -0,0 +1,199 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Store interface {
+	// EnqueueDeliveries creates a delivery of the event for every webhook subscribed to its type.
+	// Enqueueing the same event twice must not duplicate deliveries.
+	EnqueueDeliveries(ctx context.Context, event models.Event, body []byte) error
+	// ClaimDueDeliveries returns pending deliveries whose next attempt is due
+	// and postpones them by lease so that other instances skip them meanwhile.
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, attempt models.DeliveryAttempt) error
+}
+
+// Sink enqueues webhook deliveries for the events published by the outbox dispatcher.
+type Sink struct {
+	store Store
+}
+
+func NewSink(store Store) *Sink {
+	return &Sink{store: store}
+}
+
+func (s *Sink) Publish(ctx context.Context, event models.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding event %d: %w", event.ID, err)
+	}
+	return s.store.EnqueueDeliveries(ctx, event, body)
+}
+
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+type Deliverer struct {
+	store        Store
+	client       *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	batchSize    int
+}
+
+type Option func(*Deliverer)
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Deliverer) {
+		d.client = client
+	}
+}
+
+// WithMaxAttempts sets the number of attempts after which a delivery goes to the dead letters.
+func WithMaxAttempts(n int) Option {
+	return func(d *Deliverer) {
+		d.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay after the first failed attempt,
+// which doubles after every further failure up to max.
+func WithBackoff(base, max time.Duration) Option {
+	return func(d *Deliverer) {
+		d.backoff = base
+		d.maxBackoff = max
+	}
+}
+
+func WithPollInterval(interval time.Duration) Option {
+	return func(d *Deliverer) {
+		d.pollInterval = interval
+	}
+}
+
+func NewDeliverer(store Store, opts ...Option) *Deliverer {
+	d := &Deliverer{
+		store:        store,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		maxAttempts:  8,
+		backoff:      10 * time.Second,
+		maxBackoff:   time.Hour,
+		pollInterval: time.Second,
+		batchSize:    20,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run sends due deliveries until the context is cancelled.
+func (d *Deliverer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("error delivering webhooks", logging.Err(err))
+			}
+		}
+	}
+}
+
+// DeliverDue makes one attempt of up to batchSize due deliveries and returns the number
+// of attempts. Deliveries are claimed one at a time, so that the lease only has to cover
+// a single send and the deliveries still due are left to other instances meanwhile.
+func (d *Deliverer) DeliverDue(ctx context.Context) (int, error) {
+	lease := d.client.Timeout + time.Minute
+	for n := 0; n < d.batchSize; n++ {
+		deliveries, err := d.store.ClaimDueDeliveries(ctx, 1, lease)
+		if err != nil || len(deliveries) == 0 {
+			return n, err
+		}
+
+		delivery := deliveries[0]
+		attempt := d.attempt(ctx, &delivery)
+		if err := d.store.RecordAttempt(ctx, attempt); err != nil {
+			return n, fmt.Errorf("error recording attempt of delivery %d: %w", delivery.ID, err)
+		}
+	}
+	return d.batchSize, nil
+}
+
+func (d *Deliverer) attempt(ctx context.Context, delivery *models.WebhookDelivery) models.DeliveryAttempt {
+	attempt := models.DeliveryAttempt{DeliveryID: delivery.ID, Status: models.DeliverySucceeded, AttemptedAt: time.Now()}
+
+	statusCode, err := d.send(ctx, delivery)
+	attempt.StatusCode = statusCode
+	attempt.DurationMS = float64(time.Since(attempt.AttemptedAt).Microseconds()) / 1000
+	if err == nil {
+		return attempt
+	}
+
+	attempt.Error = err.Error()
+	attempts := delivery.Attempts + 1
+	if attempts >= d.maxAttempts {
+		attempt.Status = models.DeliveryDead
+		slog.Warn("webhook delivery is dead", "delivery_id", delivery.ID, "url", delivery.URL, "attempts", attempts, logging.Err(err))
+		return attempt
+	}
+
+	attempt.Status = models.DeliveryPending
+	attempt.NextAttemptAt = time.Now().Add(d.backoffAfter(attempts))
+	return attempt
+}
+
+func (d *Deliverer) backoffAfter(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
+
+func (d *Deliverer) send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("error preparing request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
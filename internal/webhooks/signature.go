@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SignatureHeader = "X-Webhook-Signature"
+
+var InvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the value of the signature header for a body sent at the given time:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">".
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, computeMAC(secret, ts, body))
+}
+
+// Verify checks the signature header of a received body and rejects
+// signatures older than tolerance, if tolerance is positive.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, mac string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			mac = value
+		}
+	}
+	if ts == "" || mac == "" {
+		return InvalidSignature
+	}
+
+	if tolerance > 0 {
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return InvalidSignature
+		}
+		if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+			return fmt.Errorf("%w: timestamp outside of tolerance", InvalidSignature)
+		}
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(computeMAC(secret, ts, body))) {
+		return InvalidSignature
+	}
+	return nil
+}
+
+func computeMAC(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,87 @@
+DROP FUNCTION IF EXISTS get_group_songs_info(TEXT, TEXT, INT, DATE, DATE);
+DROP FUNCTION IF EXISTS get_songs_info(TEXT, TEXT, INT, DATE, DATE);
+DROP PROCEDURE IF EXISTS update_song_info(INT, TEXT, TEXT, DATE, TEXT, TEXT);
+DROP FUNCTION IF EXISTS add_song(TEXT, TEXT, DATE, TEXT, TEXT, TEXT[]);
+DROP FUNCTION IF EXISTS release_period_end(DATE, TEXT);
+DROP INDEX IF EXISTS idx_songs_release_date;
+ALTER TABLE songs DROP COLUMN IF EXISTS release_date_precision;
+
+
+CREATE OR REPLACE FUNCTION add_song(
+    song_name_ TEXT,
+    group_name_ TEXT,
+    release_date_ DATE,
+    link_ TEXT,
+    verses TEXT[]
+) RETURNS INT AS $$
+DECLARE
+    new_song_id INT;
+BEGIN
+    INSERT INTO songs (song_name, group_name, release_date, link)
+    VALUES ($1, $2, $3, $4)
+        RETURNING id INTO new_song_id;
+
+    FOR i IN 1..array_length(verses, 1) LOOP
+        INSERT INTO song_lyrics (song_id, verse_number, verse_text)
+        VALUES (new_song_id, i, verses[i]);
+    END LOOP;
+
+    RETURN new_song_id;
+END;
+$$ LANGUAGE plpgsql;
+
+
+CREATE OR REPLACE PROCEDURE update_song_info(
+    id_ INT,
+    song_name_ TEXT,
+    group_name_ TEXT,
+    release_date_ DATE,
+    link_ TEXT
+) AS $$
+BEGIN
+    UPDATE songs
+    SET song_name = CASE WHEN $2 <> '' THEN $2 ELSE song_name END,
+        group_name = CASE WHEN $3 <> '' THEN $3 ELSE group_name END,
+        release_date = CASE WHEN $4 <> '0001-01-01' THEN $4 ELSE release_date END,
+        link = CASE WHEN $5 <> '' THEN $5 ELSE link END
+    WHERE id = $1;
+
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'Song with id % not found', id_;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+
+CREATE OR REPLACE FUNCTION get_songs_info(
+    prev_song TEXT,
+    prev_group TEXT,
+    limit_verse INT
+) RETURNS SETOF songs AS $$
+BEGIN
+    RETURN QUERY
+    SELECT *
+    FROM songs
+    WHERE song_name >= $1
+      AND group_name > $2
+    ORDER BY song_name, group_name
+    LIMIT $3;
+END;
+$$ LANGUAGE plpgsql;
+
+
+CREATE OR REPLACE FUNCTION get_group_songs_info(
+    group_name_ TEXT,
+    prev_song TEXT,
+    limit_verse INT
+) RETURNS SETOF songs AS $$
+BEGIN
+    RETURN QUERY
+    SELECT *
+    FROM songs
+    WHERE group_name = $1
+      AND song_name > $2
+    ORDER BY song_name
+    LIMIT $3;
+END;
+$$ LANGUAGE plpgsql;
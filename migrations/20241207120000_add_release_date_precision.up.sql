@@ -0,0 +1,130 @@
+ALTER TABLE songs
+    ADD COLUMN release_date_precision TEXT NOT NULL DEFAULT 'day'
+        CHECK (release_date_precision IN ('year', 'month', 'day'));
+
+CREATE INDEX idx_songs_release_date ON songs (release_date);
+
+
+CREATE OR REPLACE FUNCTION release_period_end(
+    release_date_ DATE,
+    precision_ TEXT
+) RETURNS DATE AS $$
+    SELECT CASE precision_
+        WHEN 'year' THEN (release_date_ + INTERVAL '1 year')::DATE
+        WHEN 'month' THEN (release_date_ + INTERVAL '1 month')::DATE
+        ELSE release_date_ + 1
+    END;
+$$ LANGUAGE sql IMMUTABLE;
+
+
+DROP FUNCTION IF EXISTS add_song(TEXT, TEXT, DATE, TEXT, TEXT[]);
+
+CREATE OR REPLACE FUNCTION add_song(
+    song_name_ TEXT,
+    group_name_ TEXT,
+    release_date_ DATE,
+    release_date_precision_ TEXT,
+    link_ TEXT,
+    verses TEXT[]
+) RETURNS INT AS $$
+DECLARE
+    new_song_id INT;
+BEGIN
+    INSERT INTO songs (song_name, group_name, release_date, release_date_precision, link)
+    VALUES ($1, $2, $3, $4, $5)
+        RETURNING id INTO new_song_id;
+
+    FOR i IN 1..array_length(verses, 1) LOOP
+        INSERT INTO song_lyrics (song_id, verse_number, verse_text)
+        VALUES (new_song_id, i, verses[i]);
+    END LOOP;
+
+    RETURN new_song_id;
+END;
+$$ LANGUAGE plpgsql;
+
+
+DROP PROCEDURE IF EXISTS update_song_info(INT, TEXT, TEXT, DATE, TEXT);
+
+CREATE OR REPLACE PROCEDURE update_song_info(
+    id_ INT,
+    song_name_ TEXT,
+    group_name_ TEXT,
+    release_date_ DATE,
+    release_date_precision_ TEXT,
+    link_ TEXT
+) AS $$
+BEGIN
+    UPDATE songs
+    SET song_name = CASE WHEN $2 <> '' THEN $2 ELSE song_name END,
+        group_name = CASE WHEN $3 <> '' THEN $3 ELSE group_name END,
+        release_date = CASE WHEN $5 <> '' THEN $4 ELSE release_date END,
+        release_date_precision = CASE WHEN $5 <> '' THEN $5 ELSE release_date_precision END,
+        link = CASE WHEN $6 <> '' THEN $6 ELSE link END
+    WHERE id = $1;
+
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'Song with id % not found', id_;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+
+DROP FUNCTION IF EXISTS get_songs_info(TEXT, TEXT, INT);
+
+CREATE OR REPLACE FUNCTION get_songs_info(
+    prev_song TEXT,
+    prev_group TEXT,
+    limit_verse INT,
+    released_from DATE,
+    released_to DATE
+) RETURNS TABLE(
+    id INT,
+    song_name TEXT,
+    group_name TEXT,
+    release_date DATE,
+    release_date_precision TEXT,
+    link TEXT
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT s.id, s.song_name, s.group_name, s.release_date, s.release_date_precision, s.link
+    FROM songs s
+    WHERE s.song_name >= $1
+      AND s.group_name > $2
+      AND ($4 IS NULL OR s.release_date >= $4)
+      AND ($5 IS NULL OR release_period_end(s.release_date, s.release_date_precision) <= $5)
+    ORDER BY s.song_name, s.group_name
+    LIMIT $3;
+END;
+$$ LANGUAGE plpgsql;
+
+
+DROP FUNCTION IF EXISTS get_group_songs_info(TEXT, TEXT, INT);
+
+CREATE OR REPLACE FUNCTION get_group_songs_info(
+    group_name_ TEXT,
+    prev_song TEXT,
+    limit_verse INT,
+    released_from DATE,
+    released_to DATE
+) RETURNS TABLE(
+    id INT,
+    song_name TEXT,
+    group_name TEXT,
+    release_date DATE,
+    release_date_precision TEXT,
+    link TEXT
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT s.id, s.song_name, s.group_name, s.release_date, s.release_date_precision, s.link
+    FROM songs s
+    WHERE s.group_name = $1
+      AND s.song_name > $2
+      AND ($4 IS NULL OR s.release_date >= $4)
+      AND ($5 IS NULL OR release_period_end(s.release_date, s.release_date_precision) <= $5)
+    ORDER BY s.song_name
+    LIMIT $3;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS song_lyrics_change ON song_lyrics;
+DROP TRIGGER IF EXISTS songs_change ON songs;
+DROP FUNCTION IF EXISTS song_lyrics_change_trigger();
+DROP FUNCTION IF EXISTS songs_change_trigger();
+DROP FUNCTION IF EXISTS record_song_change(TEXT, INT, JSONB);
+DROP FUNCTION IF EXISTS song_change_payload(songs);
+DROP INDEX IF EXISTS idx_song_changes_created_at;
+DROP INDEX IF EXISTS idx_song_changes_song_id_txid;
+DROP TABLE IF EXISTS song_changes;
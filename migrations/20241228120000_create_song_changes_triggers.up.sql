@@ -0,0 +1,101 @@
+CREATE TABLE song_changes (
+    id BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    song_id INT NOT NULL,
+    payload JSONB NOT NULL,
+    txid BIGINT NOT NULL DEFAULT txid_current(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_song_changes_song_id_txid ON song_changes (song_id, txid);
+CREATE INDEX idx_song_changes_created_at ON song_changes (created_at);
+
+
+CREATE OR REPLACE FUNCTION song_change_payload(song songs) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', song.id,
+        'song', song.song_name,
+        'group', song.group_name,
+        'releaseDate', to_char(song.release_date, CASE song.release_date_precision
+            WHEN 'year' THEN 'YYYY'
+            WHEN 'month' THEN 'MM.YYYY'
+            ELSE 'DD.MM.YYYY'
+        END),
+        'link', song.link
+    );
+$$ LANGUAGE sql STABLE;
+
+
+-- record_song_change appends a change to the log and wakes up the listeners.
+-- The notification only carries the id: listeners read the log from their last seen id.
+CREATE OR REPLACE FUNCTION record_song_change(
+    event_type_ TEXT,
+    song_id_ INT,
+    payload_ JSONB
+) RETURNS VOID AS $$
+DECLARE
+    change_id BIGINT;
+BEGIN
+    INSERT INTO song_changes (event_type, song_id, payload)
+    VALUES ($1, $2, $3)
+        RETURNING id INTO change_id;
+
+    PERFORM pg_notify('song_changes', change_id::TEXT);
+END;
+$$ LANGUAGE plpgsql;
+
+
+CREATE OR REPLACE FUNCTION songs_change_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM record_song_change('song.created', NEW.id, song_change_payload(NEW));
+    ELSIF TG_OP = 'UPDATE' THEN
+        PERFORM record_song_change('song.updated', NEW.id, song_change_payload(NEW));
+    ELSE
+        PERFORM record_song_change('song.deleted', OLD.id, song_change_payload(OLD));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER songs_change
+    AFTER INSERT OR UPDATE OR DELETE ON songs
+    FOR EACH ROW EXECUTE FUNCTION songs_change_trigger();
+
+
+-- A change of the lyrics is reported as an update of the song, unless the song
+-- was already reported in the same transaction (e.g. created with its lyrics)
+-- or is gone (its lyrics are deleted with it).
+CREATE OR REPLACE FUNCTION song_lyrics_change_trigger() RETURNS TRIGGER AS $$
+DECLARE
+    changed_song_id INT;
+    song songs;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_song_id := OLD.song_id;
+    ELSE
+        changed_song_id := NEW.song_id;
+    END IF;
+
+    IF EXISTS (
+        SELECT 1
+        FROM song_changes
+        WHERE song_id = changed_song_id
+          AND txid = txid_current()
+    ) THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT * INTO song FROM songs WHERE id = changed_song_id;
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+
+    PERFORM record_song_change('song.updated', changed_song_id, song_change_payload(song));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER song_lyrics_change
+    AFTER INSERT OR UPDATE OR DELETE ON song_lyrics
+    FOR EACH ROW EXECUTE FUNCTION song_lyrics_change_trigger();
@@ -0,0 +1,14 @@
+CREATE TABLE song_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    song_name TEXT NOT NULL,
+    group_name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    song_id INT,
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_song_jobs_due ON song_jobs (next_attempt_at) WHERE status = 'pending';
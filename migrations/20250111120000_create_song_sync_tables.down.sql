@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_song_sync_diffs_run_id;
+DROP INDEX IF EXISTS uq_song_sync_diffs_pending;
+DROP TABLE IF EXISTS song_sync_diffs;
+DROP TABLE IF EXISTS song_sync_runs;
+DROP INDEX IF EXISTS idx_song_sync_state_synced_at;
+DROP TABLE IF EXISTS song_sync_state;
@@ -0,0 +1,38 @@
+-- Kept apart from songs so that recording a sync does not count as a change of the song.
+CREATE TABLE song_sync_state (
+    song_id INT PRIMARY KEY,
+    synced_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_song FOREIGN KEY (song_id) REFERENCES songs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_song_sync_state_synced_at ON song_sync_state (synced_at);
+
+INSERT INTO song_sync_state (song_id) SELECT id FROM songs;
+
+CREATE TABLE song_sync_runs (
+    id BIGSERIAL PRIMARY KEY,
+    trigger TEXT NOT NULL CHECK (trigger IN ('scheduled', 'manual')),
+    checked INT NOT NULL DEFAULT 0,
+    changed INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE TABLE song_sync_diffs (
+    id BIGSERIAL PRIMARY KEY,
+    run_id BIGINT NOT NULL,
+    song_id INT NOT NULL,
+    field TEXT NOT NULL CHECK (field IN ('releaseDate', 'link', 'lyrics')),
+    old_value TEXT NOT NULL,
+    new_value TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'applied', 'rejected')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMPTZ,
+    CONSTRAINT fk_run FOREIGN KEY (run_id) REFERENCES song_sync_runs(id) ON DELETE CASCADE,
+    CONSTRAINT fk_song FOREIGN KEY (song_id) REFERENCES songs(id) ON DELETE CASCADE
+);
+
+-- A song has at most one difference per field waiting for review.
+CREATE UNIQUE INDEX uq_song_sync_diffs_pending ON song_sync_diffs (song_id, field) WHERE status = 'pending';
+CREATE INDEX idx_song_sync_diffs_run_id ON song_sync_diffs (run_id);
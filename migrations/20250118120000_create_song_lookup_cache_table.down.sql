@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_song_lookup_cache_expires_at;
+DROP INDEX IF EXISTS idx_song_lookup_cache_group_key;
+DROP TABLE IF EXISTS song_lookup_cache;
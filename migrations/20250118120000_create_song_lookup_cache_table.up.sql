@@ -0,0 +1,15 @@
+CREATE TABLE song_lookup_cache (
+    title_key TEXT NOT NULL,
+    group_key TEXT NOT NULL,
+    found BOOLEAN NOT NULL,
+    release_date TEXT NOT NULL DEFAULT '',
+    lyrics TEXT NOT NULL DEFAULT '',
+    link TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (title_key, group_key)
+);
+
+CREATE INDEX idx_song_lookup_cache_group_key ON song_lookup_cache (group_key);
+CREATE INDEX idx_song_lookup_cache_expires_at ON song_lookup_cache (expires_at);
@@ -0,0 +1,109 @@
+-- Songs added before sources were recorded all came from the external API.
+ALTER TABLE songs ADD COLUMN source TEXT NOT NULL DEFAULT 'external-api';
+ALTER TABLE songs ALTER COLUMN source DROP DEFAULT;
+
+
+DROP FUNCTION IF EXISTS add_song(TEXT, TEXT, DATE, TEXT, TEXT, TEXT[]);
+
+CREATE OR REPLACE FUNCTION add_song(
+    song_name_ TEXT,
+    group_name_ TEXT,
+    release_date_ DATE,
+    release_date_precision_ TEXT,
+    link_ TEXT,
+    source_ TEXT,
+    verses TEXT[]
+) RETURNS INT AS $$
+DECLARE
+    new_song_id INT;
+BEGIN
+    INSERT INTO songs (song_name, group_name, release_date, release_date_precision, link, source)
+    VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id INTO new_song_id;
+
+    FOR i IN 1..array_length(verses, 1) LOOP
+        INSERT INTO song_lyrics (song_id, verse_number, verse_text)
+        VALUES (new_song_id, i, verses[i]);
+    END LOOP;
+
+    RETURN new_song_id;
+END;
+$$ LANGUAGE plpgsql;
+
+
+DROP FUNCTION IF EXISTS get_songs_info(TEXT, TEXT, INT, DATE, DATE);
+
+CREATE OR REPLACE FUNCTION get_songs_info(
+    prev_song TEXT,
+    prev_group TEXT,
+    limit_verse INT,
+    released_from DATE,
+    released_to DATE
+) RETURNS TABLE(
+    id INT,
+    song_name TEXT,
+    group_name TEXT,
+    release_date DATE,
+    release_date_precision TEXT,
+    link TEXT,
+    source TEXT
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT s.id, s.song_name, s.group_name, s.release_date, s.release_date_precision, s.link, s.source
+    FROM songs s
+    WHERE s.song_name >= $1
+      AND s.group_name > $2
+      AND ($4 IS NULL OR s.release_date >= $4)
+      AND ($5 IS NULL OR release_period_end(s.release_date, s.release_date_precision) <= $5)
+    ORDER BY s.song_name, s.group_name
+    LIMIT $3;
+END;
+$$ LANGUAGE plpgsql;
+
+
+DROP FUNCTION IF EXISTS get_group_songs_info(TEXT, TEXT, INT, DATE, DATE);
+
+CREATE OR REPLACE FUNCTION get_group_songs_info(
+    group_name_ TEXT,
+    prev_song TEXT,
+    limit_verse INT,
+    released_from DATE,
+    released_to DATE
+) RETURNS TABLE(
+    id INT,
+    song_name TEXT,
+    group_name TEXT,
+    release_date DATE,
+    release_date_precision TEXT,
+    link TEXT,
+    source TEXT
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT s.id, s.song_name, s.group_name, s.release_date, s.release_date_precision, s.link, s.source
+    FROM songs s
+    WHERE s.group_name = $1
+      AND s.song_name > $2
+      AND ($4 IS NULL OR s.release_date >= $4)
+      AND ($5 IS NULL OR release_period_end(s.release_date, s.release_date_precision) <= $5)
+    ORDER BY s.song_name
+    LIMIT $3;
+END;
+$$ LANGUAGE plpgsql;
+
+
+CREATE OR REPLACE FUNCTION song_change_payload(song songs) RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'id', song.id,
+        'song', song.song_name,
+        'group', song.group_name,
+        'releaseDate', to_char(song.release_date, CASE song.release_date_precision
+            WHEN 'year' THEN 'YYYY'
+            WHEN 'month' THEN 'MM.YYYY'
+            ELSE 'DD.MM.YYYY'
+        END),
+        'link', song.link,
+        'source', song.source
+    );
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_song_changes_txid_id;
@@ -0,0 +1,2 @@
+-- The change log is read in the order of the transactions that logged the changes.
+CREATE INDEX idx_song_changes_txid_id ON song_changes (txid, id);
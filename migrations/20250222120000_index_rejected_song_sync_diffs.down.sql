@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_song_sync_diffs_rejected;
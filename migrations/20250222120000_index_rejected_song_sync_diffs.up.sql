@@ -0,0 +1,2 @@
+-- The syncer looks up the rejected differences of every song it checks.
+CREATE INDEX idx_song_sync_diffs_rejected ON song_sync_diffs (song_id) WHERE status = 'rejected';
@@ -0,0 +1,5 @@
+-- Events that failed to publish too many times are set aside, so that they do not hold up the rest.
+ALTER TABLE outbox_events ADD COLUMN dead_lettered_at TIMESTAMPTZ;
+
+DROP INDEX idx_outbox_events_pending;
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
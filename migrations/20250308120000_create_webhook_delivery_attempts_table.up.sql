@@ -0,0 +1,13 @@
+-- Every attempt of a delivery, so that the log shows more than the last one.
+CREATE TABLE webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'succeeded', 'dead')),
+    status_code INT,
+    error TEXT,
+    duration_ms DOUBLE PRECISION NOT NULL,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT fk_delivery FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts (delivery_id, id);
@@ -0,0 +1,2 @@
+-- An event that failed to publish waits out a growing delay before it is tried again.
+ALTER TABLE outbox_events ADD COLUMN next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,25 @@
+package auth_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/auth"
+	"strings"
+	"testing"
+)
+
+func TestGenerateKey(t *testing.T) {
+	first, err := auth.GenerateKey()
+	require.NoError(t, err)
+	second, err := auth.GenerateKey()
+	require.NoError(t, err)
+
+	require.NotEqual(t, first, second)
+	require.True(t, strings.HasPrefix(first, auth.KeyPrefix))
+	require.True(t, strings.HasPrefix(first, auth.DisplayPrefix(first)))
+	require.Less(t, len(auth.DisplayPrefix(first)), len(first), "The prefix must not reveal the key")
+}
+
+func TestHashKey(t *testing.T) {
+	require.Equal(t, auth.HashKey("sl_key"), auth.HashKey("sl_key"))
+	require.NotEqual(t, auth.HashKey("sl_key"), auth.HashKey("sl_other"))
+}
@@ -0,0 +1,127 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/auth"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var secret = []byte("test-secret")
+
+func claims(roles any) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "alice",
+		"iss":   "accounts",
+		"aud":   "song-library",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"roles": roles,
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestJWTVerifier_HMAC(t *testing.T) {
+	verifier, err := auth.NewJWTVerifier(
+		auth.WithHMACSecret(secret),
+		auth.WithIssuer("accounts"),
+		auth.WithAudience("song-library"),
+		auth.WithRoleMapping(map[string]string{"song-editor": "write"}),
+	)
+	require.NoError(t, err)
+
+	caller, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, secret, claims([]string{"song-editor", "billing"}), ""))
+	require.NoError(t, err)
+	require.Equal(t, &auth.Principal{ID: "alice", Subject: "alice", Method: auth.MethodJWT, Roles: []string{"write"}}, caller)
+	require.True(t, caller.HasRole("read"))
+	require.False(t, caller.HasRole("admin"))
+
+	caller, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, secret, claims("read admin"), ""))
+	require.NoError(t, err)
+	require.Equal(t, []string{"read", "admin"}, caller.Roles, "Roles may be a space separated string")
+
+	expired := claims("read")
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	noExpiry := claims("read")
+	delete(noExpiry, "exp")
+	otherAudience := claims("read")
+	otherAudience["aud"] = "billing"
+	noSubject := claims("read")
+	delete(noSubject, "sub")
+
+	rejected := map[string]string{
+		"wrong secret":   sign(t, jwt.SigningMethodHS256, []byte("other"), claims("read"), ""),
+		"expired":        sign(t, jwt.SigningMethodHS256, secret, expired, ""),
+		"no expiry":      sign(t, jwt.SigningMethodHS256, secret, noExpiry, ""),
+		"other audience": sign(t, jwt.SigningMethodHS256, secret, otherAudience, ""),
+		"no subject":     sign(t, jwt.SigningMethodHS256, secret, noSubject, ""),
+		"unsigned":       sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims("admin"), ""),
+		"HS512":          sign(t, jwt.SigningMethodHS512, secret, claims("read"), ""),
+		"garbage":        "not.a.token",
+	}
+	for name, token := range rejected {
+		_, err := verifier.Verify(token)
+		require.Error(t, err, "Expected the %s token to be rejected", name)
+	}
+}
+
+func TestJWTVerifier_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "key-1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	verifier, err := auth.NewJWTVerifier(auth.WithJWKSFile(path))
+	require.NoError(t, err)
+
+	caller, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, key, claims([]string{"admin"}), "key-1"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin"}, caller.Roles)
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, key, claims("admin"), ""))
+	require.NoError(t, err, "The only key may be used without a key id")
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, other, claims("admin"), "key-1"))
+	require.Error(t, err)
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, key, claims("admin"), "key-2"))
+	require.Error(t, err)
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, secret, claims("admin"), ""))
+	require.Error(t, err, "HS256 must not be accepted without a secret")
+}
+
+func TestNewJWTVerifier_RequiresKeys(t *testing.T) {
+	_, err := auth.NewJWTVerifier()
+	require.Error(t, err)
+
+	_, err = auth.NewJWTVerifier(auth.WithJWKSFile(filepath.Join(t.TempDir(), "missing.json")))
+	require.Error(t, err)
+}
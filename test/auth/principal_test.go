@@ -0,0 +1,16 @@
+package auth_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/auth"
+	"testing"
+)
+
+func TestPrincipal_Key(t *testing.T) {
+	first := &auth.Principal{ID: "1", Subject: "app", Method: auth.MethodAPIKey}
+	second := &auth.Principal{ID: "2", Subject: "app", Method: auth.MethodAPIKey}
+
+	require.Equal(t, first.String(), second.String())
+	require.NotEqual(t, first.Key(), second.Key(), "Keys of the same name are told apart")
+	require.Equal(t, "api-key:1", first.Key())
+}
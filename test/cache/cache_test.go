@@ -0,0 +1,180 @@
+package cache_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/cache"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"testing"
+	"time"
+)
+
+// countingRepo serves fixed data and counts how often each read reaches it.
+type countingRepo struct {
+	postgres.SongRepository
+	songs  []models.SongInfo
+	lyrics map[int]string
+	reads  int
+}
+
+func (r *countingRepo) GetSongsInfo(context.Context, *models.PaginationInfo) ([]models.SongInfo, error) {
+	r.reads++
+	return r.songs, nil
+}
+
+func (r *countingRepo) GetGroupSongsInfo(_ context.Context, group string, _ *models.PaginationInfo) ([]models.SongInfo, error) {
+	r.reads++
+	var songs []models.SongInfo
+	for _, song := range r.songs {
+		if song.Group == group {
+			songs = append(songs, song)
+		}
+	}
+	return songs, nil
+}
+
+func (r *countingRepo) GetSongLyrics(_ context.Context, id, _, _ int) (string, error) {
+	r.reads++
+	lyrics, ok := r.lyrics[id]
+	if !ok {
+		return "", postgres.SongNotFound
+	}
+	return lyrics, nil
+}
+
+func (r *countingRepo) AddSong(_ context.Context, song *models.Song) (int, error) {
+	song.ID = len(r.songs) + 1
+	r.songs = append(r.songs, song.SongInfo)
+	r.lyrics[song.ID] = song.Lyrics
+	return song.ID, nil
+}
+
+func (r *countingRepo) DeleteSong(_ context.Context, id int) error {
+	delete(r.lyrics, id)
+	return nil
+}
+
+// applyingSync applies every difference by replacing the lyrics of song 1.
+type applyingSync struct {
+	postgres.SyncRepository
+	repo *countingRepo
+}
+
+func (s *applyingSync) ApplyDiff(_ context.Context, id int64) (*models.SongDiff, error) {
+	s.repo.lyrics[1] = "Verse 2"
+	return &models.SongDiff{ID: id, SongID: 1, Field: models.SyncFieldLyrics, Status: models.DiffApplied}, nil
+}
+
+func newRepo() *countingRepo {
+	return &countingRepo{
+		songs:  []models.SongInfo{{ID: 1, Title: "Song 1", Group: "Group 1"}},
+		lyrics: map[int]string{1: "Verse 1"},
+	}
+}
+
+func TestCache_HitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo()
+	songs := cache.NewSongRepository(repo, 10, time.Minute)
+
+	for range 3 {
+		lyrics, err := songs.GetSongLyrics(ctx, 1, 0, 10)
+		require.NoError(t, err)
+		require.Equal(t, "Verse 1", lyrics)
+	}
+	_, err := songs.GetSongLyrics(ctx, 1, 1, 10)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, repo.reads)
+	require.Equal(t, cache.Stats{Hits: 2, Misses: 2, Entries: 2, Capacity: 10}, songs.Stats())
+}
+
+func TestCache_ErrorsAreNotCached(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo()
+	songs := cache.NewSongRepository(repo, 10, time.Minute)
+
+	for range 2 {
+		_, err := songs.GetSongLyrics(ctx, 2, 0, 10)
+		require.ErrorIs(t, err, postgres.SongNotFound)
+	}
+	require.Equal(t, 2, repo.reads)
+	require.Zero(t, songs.Stats().Entries)
+}
+
+func TestCache_WritesInvalidate(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo()
+	songs := cache.NewSongRepository(repo, 10, time.Minute)
+	hint := &models.PaginationInfo{Limit: 10}
+
+	list, err := songs.GetSongsInfo(ctx, hint)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	_, err = songs.AddSong(ctx, &models.Song{SongInfo: models.SongInfo{Title: "Song 2", Group: "Group 1"}})
+	require.NoError(t, err)
+
+	list, err = songs.GetSongsInfo(ctx, hint)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	list, err = songs.GetGroupSongsInfo(ctx, "Group 1", hint)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	_, err = songs.GetSongLyrics(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.NoError(t, songs.DeleteSong(ctx, 1))
+	_, err = songs.GetSongLyrics(ctx, 1, 0, 10)
+	require.ErrorIs(t, err, postgres.SongNotFound)
+}
+
+func TestCache_AppliedDiffsInvalidate(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo()
+	songs := cache.NewSongRepository(repo, 10, time.Minute)
+	sync := cache.NewSyncRepository(&applyingSync{repo: repo}, songs)
+
+	lyrics, err := songs.GetSongLyrics(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, "Verse 1", lyrics)
+
+	_, err = sync.ApplyDiff(ctx, 1)
+	require.NoError(t, err)
+	lyrics, err = songs.GetSongLyrics(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, "Verse 2", lyrics)
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo()
+	songs := cache.NewSongRepository(repo, 2, time.Minute)
+
+	for offset := range 3 {
+		_, err := songs.GetSongLyrics(ctx, 1, offset, 10)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, songs.Stats().Entries)
+
+	_, err := songs.GetSongLyrics(ctx, 1, 2, 10)
+	require.NoError(t, err)
+	_, err = songs.GetSongLyrics(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 4, repo.reads)
+}
+
+func TestCache_EntriesExpire(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo()
+	songs := cache.NewSongRepository(repo, 10, 20*time.Millisecond)
+
+	_, err := songs.GetSongLyrics(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = songs.GetSongLyrics(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, repo.reads)
+}
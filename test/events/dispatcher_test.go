@@ -0,0 +1,172 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/events"
+	"github.com/yankokirill/song-library/internal/models"
+	"testing"
+	"time"
+)
+
+// memoryOutbox mimics the ordering and retry semantics of the Postgres outbox.
+// It records the backoff of failed events but retries them right away.
+type memoryOutbox struct {
+	pending      []models.Event
+	published    []models.Event
+	deadLettered []models.Event
+	attempts     map[int64]int
+	delays       []time.Duration
+}
+
+func (o *memoryOutbox) ProcessEvents(ctx context.Context, limit, maxAttempts int, backoff func(int) time.Duration,
+	publish func(context.Context, models.Event) error) (int, error) {
+	n := 0
+	for len(o.pending) > 0 && n < limit {
+		if err := publish(ctx, o.pending[0]); err != nil {
+			o.attempts[o.pending[0].ID]++
+			if o.attempts[o.pending[0].ID] < maxAttempts {
+				o.delays = append(o.delays, backoff(o.attempts[o.pending[0].ID]))
+				return n, nil
+			}
+			o.deadLettered = append(o.deadLettered, o.pending[0])
+			o.pending = o.pending[1:]
+			continue
+		}
+		o.published = append(o.published, o.pending[0])
+		o.pending = o.pending[1:]
+		n++
+	}
+	return n, nil
+}
+
+func (o *memoryOutbox) DeletePublishedEvents(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newOutbox(n int) *memoryOutbox {
+	outbox := &memoryOutbox{attempts: make(map[int64]int)}
+	for i := range n {
+		outbox.pending = append(outbox.pending, models.Event{
+			ID:     int64(i + 1),
+			Type:   models.EventSongCreated,
+			SongID: i + 1,
+		})
+	}
+	return outbox
+}
+
+func TestDispatcher_PublishesInOrder(t *testing.T) {
+	outbox := newOutbox(250)
+	var received []int64
+	sink := events.SinkFunc(func(_ context.Context, event models.Event) error {
+		received = append(received, event.ID)
+		return nil
+	})
+
+	dispatcher := events.NewDispatcher(outbox, time.Second, sink, events.LogSink{})
+	n, err := dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 250, n)
+	require.Empty(t, outbox.pending)
+	require.Len(t, received, 250)
+	for i, id := range received {
+		require.Equal(t, int64(i+1), id)
+	}
+}
+
+func TestDispatcher_RetriesFailedEvent(t *testing.T) {
+	outbox := newOutbox(3)
+	failures := 2
+	var received []int64
+	sink := events.SinkFunc(func(_ context.Context, event models.Event) error {
+		if event.ID == 2 && failures > 0 {
+			failures--
+			return errors.New("sink unavailable")
+		}
+		received = append(received, event.ID)
+		return nil
+	})
+
+	dispatcher := events.NewDispatcher(outbox, time.Second, sink)
+	for range 2 {
+		n, err := dispatcher.DispatchOnce(context.Background())
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 1)
+		require.Len(t, outbox.pending, 2, "Events after a failed one must wait")
+	}
+
+	n, err := dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []int64{1, 2, 3}, received)
+}
+
+func TestDispatcher_DeadLettersFailingEvent(t *testing.T) {
+	outbox := newOutbox(3)
+	var received []int64
+	sink := events.SinkFunc(func(_ context.Context, event models.Event) error {
+		if event.ID == 2 {
+			return errors.New("sink rejects the event")
+		}
+		received = append(received, event.ID)
+		return nil
+	})
+
+	dispatcher := events.NewDispatcher(outbox, time.Second, sink)
+	for range 9 {
+		_, err := dispatcher.DispatchOnce(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []int64{1}, received)
+	}
+
+	_, err := dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 3}, received, "The events after a dead-lettered one go on")
+	require.Len(t, outbox.deadLettered, 1)
+	require.Equal(t, int64(2), outbox.deadLettered[0].ID)
+	require.Empty(t, outbox.pending)
+}
+
+func TestDispatcher_BacksOffFailingEvent(t *testing.T) {
+	outbox := newOutbox(1)
+	sink := events.SinkFunc(func(context.Context, models.Event) error {
+		return errors.New("sink unavailable")
+	})
+
+	dispatcher := events.NewDispatcher(outbox, time.Second, sink)
+	for range 10 {
+		_, err := dispatcher.DispatchOnce(context.Background())
+		require.NoError(t, err)
+	}
+
+	require.Len(t, outbox.delays, 9)
+	require.Equal(t, time.Second, outbox.delays[0])
+	for i := 1; i < len(outbox.delays); i++ {
+		require.Equal(t, min(2*outbox.delays[i-1], 5*time.Minute), outbox.delays[i])
+	}
+	require.Len(t, outbox.deadLettered, 1)
+}
+
+func TestDispatcher_AllSinksMustSucceed(t *testing.T) {
+	outbox := newOutbox(1)
+	delivered := 0
+	ok := events.SinkFunc(func(context.Context, models.Event) error {
+		delivered++
+		return nil
+	})
+	failing := events.SinkFunc(func(context.Context, models.Event) error {
+		return errors.New("sink unavailable")
+	})
+
+	dispatcher := events.NewDispatcher(outbox, time.Second, ok, failing)
+	n, err := dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Len(t, outbox.pending, 1)
+
+	_, err = dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, delivered, "The event is delivered again to every sink")
+}
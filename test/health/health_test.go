@@ -0,0 +1,128 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	delivery "github.com/yankokirill/song-library/internal/delivery/http"
+	"github.com/yankokirill/song-library/internal/health"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counted returns a check failing with err, and how many times it ran.
+func counted(err error) (func(context.Context) error, *atomic.Int32) {
+	var runs atomic.Int32
+	return func(context.Context) error {
+		runs.Add(1)
+		return err
+	}, &runs
+}
+
+func TestChecker(t *testing.T) {
+	database, runs := counted(nil)
+	checker := health.NewChecker([]health.Check{
+		{Name: "database", Run: database},
+		{Name: "migrations", Run: func(context.Context) error { return nil }},
+	}, health.WithCacheTTL(50*time.Millisecond))
+
+	report := checker.Check(context.Background())
+	require.True(t, report.Ready())
+	require.Len(t, report.Checks, 2)
+	require.Equal(t, health.StatusOK, report.Checks["database"].Status)
+	require.GreaterOrEqual(t, report.Checks["database"].LatencyMS, 0.0)
+
+	checker.Check(context.Background())
+	require.EqualValues(t, 1, runs.Load(), "A fresh report is served from the cache")
+	time.Sleep(60 * time.Millisecond)
+	checker.Check(context.Background())
+	require.EqualValues(t, 2, runs.Load(), "A stale report is checked again")
+
+	checker.Drain()
+	report = checker.Check(context.Background())
+	require.False(t, report.Ready())
+	require.Equal(t, health.StatusDraining, report.Status)
+	require.EqualValues(t, 2, runs.Load(), "A draining service is not checked")
+}
+
+func TestChecker_Failures(t *testing.T) {
+	checker := health.NewChecker([]health.Check{
+		{Name: "database", Run: func(context.Context) error { return nil }},
+		{Name: "migrations", Run: func(context.Context) error {
+			return errors.New("database is at version 1, expected 2")
+		}},
+		{Name: "external_api", Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}, health.WithTimeout(20*time.Millisecond))
+
+	start := time.Now()
+	report := checker.Check(context.Background())
+	require.Less(t, time.Since(start), time.Second, "Slow checks time out")
+	require.Equal(t, health.StatusFailed, report.Status)
+	require.Equal(t, health.StatusOK, report.Checks["database"].Status)
+	require.Equal(t, health.StatusFailed, report.Checks["migrations"].Status)
+	require.Equal(t, "database is at version 1, expected 2", report.Checks["migrations"].Error)
+	require.Equal(t, health.StatusFailed, report.Checks["external_api"].Status)
+	require.Contains(t, report.Checks["external_api"].Error, "deadline exceeded")
+}
+
+func TestReachable(t *testing.T) {
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	check := health.Reachable(server.Client(), server.URL)
+
+	require.NoError(t, check(context.Background()), "Any answer but a server error will do")
+	status = http.StatusServiceUnavailable
+	require.Error(t, check(context.Background()))
+	server.Close()
+	require.Error(t, check(context.Background()))
+}
+
+func TestServer_Probes(t *testing.T) {
+	var failure error
+	checker := health.NewChecker([]health.Check{
+		{Name: "database", Run: func(context.Context) error { return failure }},
+	}, health.WithCacheTTL(0))
+	server := httptest.NewServer(delivery.NewServer(postgres.SongRepository(nil), nil, "", delivery.WithHealth(checker)).Routes())
+	defer server.Close()
+
+	probe := func(path string) (int, health.Report) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var report health.Report
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		return resp.StatusCode, report
+	}
+
+	status, report := probe("/healthz")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, health.StatusOK, report.Status)
+
+	status, report = probe("/readyz")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, health.StatusOK, report.Checks["database"].Status)
+
+	failure = errors.New("connection refused")
+	status, report = probe("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, "connection refused", report.Checks["database"].Error)
+
+	checker.Drain()
+	status, report = probe("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, health.StatusDraining, report.Status)
+
+	status, _ = probe("/healthz")
+	require.Equal(t, http.StatusOK, status, "A draining service is still alive")
+}
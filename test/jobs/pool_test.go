@@ -0,0 +1,194 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/jobs"
+	"github.com/yankokirill/song-library/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore hands out each pending job once per claim, like an expired lease would.
+type memoryStore struct {
+	mu       sync.Mutex
+	jobs     map[string]*models.Job
+	released []time.Duration
+}
+
+func newStore(ids ...string) *memoryStore {
+	store := &memoryStore{jobs: make(map[string]*models.Job)}
+	for _, id := range ids {
+		store.jobs[id] = &models.Job{ID: id, Status: models.JobPending, Title: id}
+	}
+	return store
+}
+
+func (s *memoryStore) ClaimJobs(_ context.Context, limit int, _ time.Duration) ([]models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []models.Job
+	for _, job := range s.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if job.Status == models.JobPending && job.UpdatedAt.IsZero() {
+			job.Attempts++
+			job.UpdatedAt = time.Now()
+			claimed = append(claimed, *job)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memoryStore) CompleteJob(_ context.Context, id string, songID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].Status, s.jobs[id].SongID = models.JobSucceeded, songID
+	return nil
+}
+
+func (s *memoryStore) FailJob(_ context.Context, id string, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].Status, s.jobs[id].Error = models.JobFailed, reason
+	return nil
+}
+
+func (s *memoryStore) ReleaseJob(_ context.Context, id string, after time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].UpdatedAt = time.Time{}
+	s.released = append(s.released, after)
+	return nil
+}
+
+// expire makes the claimed pending jobs claimable again.
+func (s *memoryStore) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		job.UpdatedAt = time.Time{}
+	}
+}
+
+func (s *memoryStore) job(id string) models.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.jobs[id]
+}
+
+func TestPool_RecordsOutcome(t *testing.T) {
+	store := newStore("ok", "bad")
+	pool := jobs.NewPool(store, func(_ context.Context, job models.Job) (int, error) {
+		if job.Title == "bad" {
+			return 0, errors.New("404 Not Found")
+		}
+		return 7, nil
+	})
+
+	for range 2 {
+		n, err := pool.RunOnce(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	}
+	n, err := pool.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	require.Equal(t, models.JobSucceeded, store.job("ok").Status)
+	require.Equal(t, 7, store.job("ok").SongID)
+	require.Equal(t, models.JobFailed, store.job("bad").Status)
+	require.Equal(t, "404 Not Found", store.job("bad").Error)
+}
+
+func TestPool_LeavesInterruptedJobs(t *testing.T) {
+	store := newStore("job")
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := jobs.NewPool(store, func(ctx context.Context, _ models.Job) (int, error) {
+		cancel()
+		return 0, ctx.Err()
+	}, jobs.WithMaxAttempts(2))
+
+	_, err := pool.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models.JobPending, store.job("job").Status)
+
+	store.expire()
+	_, err = pool.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models.JobPending, store.job("job").Status)
+
+	store.expire()
+	_, err = pool.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, models.JobFailed, store.job("job").Status)
+	require.Equal(t, "abandoned after 2 attempts", store.job("job").Error)
+}
+
+func TestPool_CompletesJobsDoneAtShutdown(t *testing.T) {
+	store := newStore("job")
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := jobs.NewPool(store, func(context.Context, models.Job) (int, error) {
+		cancel()
+		return 7, nil
+	})
+
+	_, err := pool.RunOnce(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models.JobSucceeded, store.job("job").Status)
+	require.Equal(t, 7, store.job("job").SongID)
+}
+
+func TestPool_ReleasesTemporaryFailures(t *testing.T) {
+	store := newStore("job")
+	pool := jobs.NewPool(store, func(context.Context, models.Job) (int, error) {
+		return 0, &jobs.TemporaryError{Err: errors.New("503 Service Unavailable"), RetryAfter: 30 * time.Second}
+	}, jobs.WithMaxAttempts(3), jobs.WithRetryDelay(time.Minute))
+
+	for range 2 {
+		_, err := pool.RunOnce(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, models.JobPending, store.job("job").Status)
+	}
+	require.Equal(t, []time.Duration{30 * time.Second, 30 * time.Second}, store.released)
+
+	_, err := pool.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, models.JobFailed, store.job("job").Status, "The last attempt fails the job")
+	require.Equal(t, "503 Service Unavailable", store.job("job").Error)
+}
+
+func TestPool_Run(t *testing.T) {
+	store := newStore("a", "b", "c", "d", "e")
+	var mu sync.Mutex
+	handled := 0
+	pool := jobs.NewPool(store, func(context.Context, models.Job) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled++
+		return handled, nil
+	}, jobs.WithWorkers(3), jobs.WithPollInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return handled == 5
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		require.Equal(t, models.JobSucceeded, store.job(id).Status)
+	}
+}
@@ -0,0 +1,46 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/logging"
+	"strings"
+	"testing"
+)
+
+func TestNew_AddsRequestID(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "info", "json")
+	require.NoError(t, err)
+
+	ctx := logging.WithRequestID(context.Background(), "host/abc-000001")
+	logger.With("component", "test").InfoContext(ctx, "song added", "song_id", 7)
+	logger.DebugContext(ctx, "hidden below the level")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record), "Expected a single JSON record, got %s", out.String())
+	require.Equal(t, "song added", record["msg"])
+	require.Equal(t, "INFO", record["level"])
+	require.Equal(t, "host/abc-000001", record["request_id"])
+	require.Equal(t, "test", record["component"])
+	require.EqualValues(t, 7, record["song_id"])
+}
+
+func TestNew_Text(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "DEBUG", "text")
+	require.NoError(t, err)
+
+	logger.Debug("replica is healthy", "replica", 1)
+	require.True(t, strings.Contains(out.String(), `msg="replica is healthy" replica=1`), out.String())
+	require.NotContains(t, out.String(), "request_id", "Records without a request must not carry its id")
+}
+
+func TestNew_RejectsInvalidSettings(t *testing.T) {
+	_, err := logging.New(&bytes.Buffer{}, "loud", "json")
+	require.Error(t, err)
+	_, err = logging.New(&bytes.Buffer{}, "info", "xml")
+	require.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package mockapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/mockapi"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func newServer(t *testing.T, faults *mockapi.Faults) *httptest.Server {
+	t.Helper()
+	songs, err := mockapi.ReadSongs("testdata/songs.json")
+	require.NoError(t, err)
+
+	server := httptest.NewServer(mockapi.NewHandler(songs, faults))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getInfo(t *testing.T, server *httptest.Server, title, group string) *http.Response {
+	t.Helper()
+	query := url.Values{}
+	query.Set("song", title)
+	query.Set("group", group)
+	resp, err := http.Get(server.URL + "/info?" + query.Encode())
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHandler_ServesDataFile(t *testing.T) {
+	server := newServer(t, &mockapi.Faults{})
+
+	resp := getInfo(t, server, "Clocks", "Coldplay")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var detail struct {
+		ReleaseDate string `json:"releaseDate"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&detail))
+	require.Equal(t, "24.03.2003", detail.ReleaseDate)
+
+	resp = getInfo(t, server, "Yellow", "Coldplay")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode, "only the data file is served")
+}
+
+func TestFaults_ErrorRate(t *testing.T) {
+	faults := &mockapi.Faults{}
+	server := newServer(t, faults)
+
+	faults.SetErrorRate(1, http.StatusServiceUnavailable)
+	faults.SetRetryAfter("3")
+	resp := getInfo(t, server, "Clocks", "Coldplay")
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "3", resp.Header.Get("Retry-After"))
+
+	faults.SetErrorRate(0, 0)
+	resp = getInfo(t, server, "Clocks", "Coldplay")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 2, faults.Requests())
+}
+
+func TestFaults_Songs(t *testing.T) {
+	faults := &mockapi.Faults{}
+	server := newServer(t, faults)
+	clocks := mockapi.SongInfo{Title: "Clocks", Group: "Coldplay"}
+
+	faults.SetSongFault(mockapi.SongFault{SongInfo: clocks, Malformed: true})
+	resp := getInfo(t, server, "Clocks", "Coldplay")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var detail map[string]any
+	require.Error(t, json.NewDecoder(resp.Body).Decode(&detail))
+
+	faults.SetSongFault(mockapi.SongFault{SongInfo: clocks, Status: http.StatusTeapot})
+	resp = getInfo(t, server, "Clocks", "Coldplay")
+	require.Equal(t, http.StatusTeapot, resp.StatusCode)
+
+	faults.SetSongFault(mockapi.SongFault{SongInfo: clocks})
+	require.Empty(t, faults.Settings().Songs)
+	resp = getInfo(t, server, "Clocks", "Coldplay")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHandler_Faults(t *testing.T) {
+	faults := &mockapi.Faults{}
+	server := newServer(t, faults)
+
+	put := func(body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/faults", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := put(`{"songs": [{"title": "Clocks", "group": "Coldplay", "status": 502}]}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, http.StatusBadGateway, getInfo(t, server, "Clocks", "Coldplay").StatusCode)
+
+	resp, err := http.Get(server.URL + "/faults")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var settings mockapi.Settings
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&settings))
+	require.Len(t, settings.Songs, 1)
+	require.Equal(t, http.StatusBadGateway, settings.Songs[0].Status)
+
+	require.Equal(t, http.StatusBadRequest, put(`{"errorRate": 2}`).StatusCode)
+	require.Equal(t, http.StatusBadRequest, put(`{"songs": [{"title": "Clocks", "status": 200}]}`).StatusCode)
+
+	require.Equal(t, http.StatusOK, put(`{}`).StatusCode)
+	require.Equal(t, http.StatusOK, getInfo(t, server, "Clocks", "Coldplay").StatusCode)
+}
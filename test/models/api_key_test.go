@@ -0,0 +1,25 @@
+package models_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/models"
+	"testing"
+)
+
+func TestAPIKey_HasScope(t *testing.T) {
+	reader := models.APIKey{Scopes: []string{models.ScopeRead}}
+	require.True(t, reader.HasScope(models.ScopeRead))
+	require.False(t, reader.HasScope(models.ScopeWrite))
+	require.False(t, reader.HasScope(models.ScopeAdmin))
+
+	writer := models.APIKey{Scopes: []string{models.ScopeWrite}}
+	require.True(t, writer.HasScope(models.ScopeRead), "Writers may also read")
+	require.True(t, writer.HasScope(models.ScopeWrite))
+	require.False(t, writer.HasScope(models.ScopeAdmin))
+
+	admin := models.APIKey{Scopes: []string{models.ScopeAdmin}}
+	for _, scope := range models.Scopes {
+		require.True(t, admin.HasScope(scope), "Admins may do everything")
+	}
+	require.False(t, (&models.APIKey{}).HasScope(models.ScopeRead))
+}
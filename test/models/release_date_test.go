@@ -0,0 +1,98 @@
+package models_test
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseReleaseDate(t *testing.T) {
+	cases := []struct {
+		input     string
+		precision models.DatePrecision
+		start     time.Time
+	}{
+		{"16.07.2006", models.PrecisionDay, time.Date(2006, time.July, 16, 0, 0, 0, 0, time.UTC)},
+		{"07.2006", models.PrecisionMonth, time.Date(2006, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{"2006", models.PrecisionYear, time.Date(2006, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		date, err := models.ParseReleaseDate(c.input)
+		require.NoError(t, err, "Failed to parse %q", c.input)
+		require.Equal(t, c.precision, date.Precision)
+		require.Equal(t, c.start, date.Time)
+		require.Equal(t, c.input, date.String())
+	}
+
+	for _, input := range []string{"", "13.2006", "32.01.2006", "7.2006", "06", "2006-7-16", "2006/07"} {
+		_, err := models.ParseReleaseDate(input)
+		require.Error(t, err, "Expected %q to be rejected", input)
+	}
+}
+
+func TestParseReleaseDate_ISO(t *testing.T) {
+	for input, legacy := range map[string]string{
+		"2006-07-16": "16.07.2006",
+		"2006-07":    "07.2006",
+		"2006":       "2006",
+	} {
+		date, err := models.ParseReleaseDate(input)
+		require.NoError(t, err, "Failed to parse %q", input)
+		require.Equal(t, legacy, date.Format(models.DateFormatLegacy))
+		require.Equal(t, input, date.Format(models.DateFormatISO))
+	}
+}
+
+func TestReleaseDate_Compare(t *testing.T) {
+	var dates []models.ReleaseDate
+	for _, s := range []string{"16.07.2006", "2007", "01.01.2006", "07.2006", "2006", "01.2006"} {
+		date, err := models.ParseReleaseDate(s)
+		require.NoError(t, err)
+		dates = append(dates, date)
+	}
+
+	slices.SortFunc(dates, models.ReleaseDate.Compare)
+	var sorted []string
+	for _, date := range dates {
+		sorted = append(sorted, date.String())
+	}
+	require.Equal(t, []string{"2006", "01.2006", "01.01.2006", "07.2006", "16.07.2006", "2007"}, sorted)
+	require.Zero(t, dates[1].Compare(dates[1]))
+}
+
+func TestReleaseDate_Equal(t *testing.T) {
+	year, _ := models.ParseReleaseDate("2006")
+	month, _ := models.ParseReleaseDate("01.2006")
+	day, _ := models.ParseReleaseDate("01.01.2006")
+	sameDay, _ := models.ParseReleaseDate("2006-01-01")
+
+	require.False(t, year.Equal(month), "The precision tells dates apart")
+	require.False(t, month.Equal(day))
+	require.True(t, day.Equal(sameDay))
+	require.True(t, month.Equal(month))
+
+	require.Equal(t, time.Date(2007, time.January, 1, 0, 0, 0, 0, time.UTC), year.End())
+	require.Equal(t, time.Date(2006, time.February, 1, 0, 0, 0, 0, time.UTC), month.End())
+	require.Equal(t, time.Date(2006, time.January, 2, 0, 0, 0, 0, time.UTC), day.End())
+}
+
+func TestReleaseDate_JSON(t *testing.T) {
+	var song models.SongInfo
+	err := json.Unmarshal([]byte(`{"song": "Yellow", "releaseDate": "06.2000"}`), &song)
+	require.NoError(t, err)
+	require.Equal(t, models.PrecisionMonth, song.ReleaseDate.Precision)
+
+	data, err := json.Marshal(song)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"releaseDate":"06.2000"`)
+
+	err = json.Unmarshal([]byte(`{"releaseDate": ""}`), &song)
+	require.NoError(t, err)
+	require.True(t, song.ReleaseDate.IsZero())
+
+	err = json.Unmarshal([]byte(`{"releaseDate": "June 2000"}`), &song)
+	require.Error(t, err)
+}
@@ -0,0 +1,27 @@
+package models_test
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/models"
+	"testing"
+)
+
+func TestSongDetail_Validate(t *testing.T) {
+	valid := models.SongDetail{ReleaseDate: "2006-07", Text: "Ooh", Link: "https://example.com/song"}
+	require.NoError(t, valid.Validate())
+
+	noLink := valid
+	noLink.Link = ""
+	require.NoError(t, noLink.Validate(), "the link is optional")
+
+	invalid := []models.SongDetail{
+		{Text: "Ooh"},
+		{ReleaseDate: "someday", Text: "Ooh"},
+		{ReleaseDate: "2006", Text: " \n\n "},
+		{ReleaseDate: "2006", Text: "Ooh", Link: "example.com/song"},
+		{ReleaseDate: "2006", Text: "Ooh", Link: "ftp://example.com/song"},
+	}
+	for _, detail := range invalid {
+		require.Error(t, detail.Validate(), "Expected %+v to be rejected", detail)
+	}
+}
@@ -0,0 +1,65 @@
+package ratelimit_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestBucket_Burst(t *testing.T) {
+	bucket := ratelimit.NewBucket(10, 3)
+	for range 3 {
+		wait, ok := bucket.Take(0)
+		require.True(t, ok)
+		require.Zero(t, wait)
+	}
+
+	wait, ok := bucket.Take(0)
+	require.False(t, ok)
+	require.InDelta(t, 100*time.Millisecond, wait, float64(10*time.Millisecond))
+}
+
+func TestBucket_TakesAhead(t *testing.T) {
+	bucket := ratelimit.NewBucket(10, 1)
+	_, ok := bucket.Take(0)
+	require.True(t, ok)
+
+	first, ok := bucket.Take(time.Second)
+	require.True(t, ok)
+	second, ok := bucket.Take(time.Second)
+	require.True(t, ok)
+	require.InDelta(t, 100*time.Millisecond, second-first, float64(10*time.Millisecond))
+
+	_, ok = bucket.Take(250 * time.Millisecond)
+	require.False(t, ok, "a refused take must not deepen the debt")
+	third, ok := bucket.Take(time.Second)
+	require.True(t, ok)
+	require.InDelta(t, 300*time.Millisecond, third, float64(10*time.Millisecond))
+}
+
+func TestBucket_Refills(t *testing.T) {
+	bucket := ratelimit.NewBucket(100, 2)
+	bucket.Take(0)
+	bucket.Take(0)
+	time.Sleep(30 * time.Millisecond)
+
+	for range 2 {
+		wait, ok := bucket.Take(0)
+		require.True(t, ok)
+		require.Zero(t, wait)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	sem := ratelimit.NewSemaphore(1)
+	require.NoError(t, sem.Acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, sem.Acquire(ctx), context.DeadlineExceeded)
+
+	sem.Release()
+	require.NoError(t, sem.Acquire(context.Background()))
+}
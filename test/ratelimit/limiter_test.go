@@ -0,0 +1,63 @@
+package ratelimit_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestLimit_Take(t *testing.T) {
+	limit := ratelimit.Limit{Rate: 2, Burst: 3}
+	now := time.Now()
+	state := limit.Full(now)
+
+	var decision ratelimit.Decision
+	for remaining := 2; remaining >= 0; remaining-- {
+		state, decision = limit.Take(state, now)
+		require.True(t, decision.Allowed)
+		require.Equal(t, 3, decision.Limit)
+		require.Equal(t, remaining, decision.Remaining)
+	}
+	require.Equal(t, 1500*time.Millisecond, decision.Reset)
+
+	state, decision = limit.Take(state, now)
+	require.False(t, decision.Allowed)
+	require.Equal(t, 500*time.Millisecond, decision.RetryAfter)
+	require.Zero(t, state.Tokens, "a refused request must not go into debt")
+
+	state, decision = limit.Take(state, now.Add(500*time.Millisecond))
+	require.True(t, decision.Allowed, "a token must be refilled after 1/rate")
+
+	_, decision = limit.Take(state, now.Add(time.Hour))
+	require.True(t, decision.Allowed)
+	require.Equal(t, 2, decision.Remaining, "the bucket must not overflow its burst")
+}
+
+func TestMemoryStore_SeparatesKeys(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Rate: 0.001, Burst: 1}
+	ctx := context.Background()
+
+	decision, err := store.Take(ctx, "alice", limit)
+	require.NoError(t, err)
+	require.True(t, decision.Allowed)
+
+	decision, err = store.Take(ctx, "alice", limit)
+	require.NoError(t, err)
+	require.False(t, decision.Allowed)
+
+	decision, err = store.Take(ctx, "bob", limit)
+	require.NoError(t, err)
+	require.True(t, decision.Allowed, "clients must not share a budget")
+	require.Equal(t, 2, store.Len())
+}
+
+func TestLimit_Validate(t *testing.T) {
+	require.NoError(t, ratelimit.Limit{Rate: 2, Burst: 10}.Validate())
+	require.Error(t, ratelimit.Limit{Rate: 0, Burst: 10}.Validate(), "a bucket that never refills")
+	require.Error(t, ratelimit.Limit{Rate: -1, Burst: 10}.Validate())
+	require.Error(t, ratelimit.Limit{Rate: 2, Burst: 0}.Validate())
+	require.Error(t, ratelimit.Limit{Rate: 0.001, Burst: 10}.Validate(), "a bucket forgotten before it refills")
+}
@@ -0,0 +1,221 @@
+package resync_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/resync"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	mu       sync.Mutex
+	songs    []models.Song
+	synced   map[int]bool
+	diffs    []models.SongDiff
+	runs     []models.SyncRun
+	finished chan struct{}
+	// recordErr, if set, fails recording the sync of every song.
+	recordErr error
+}
+
+func (s *memoryStore) StaleSongs(_ context.Context, _ time.Time, limit int) ([]models.Song, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var stale []models.Song
+	for _, song := range s.songs {
+		if !s.synced[song.ID] && len(stale) < limit {
+			stale = append(stale, song)
+		}
+	}
+	return stale, nil
+}
+
+func (s *memoryStore) RejectedDiffs(_ context.Context, songID int) ([]models.SongDiff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rejected []models.SongDiff
+	for _, diff := range s.diffs {
+		if diff.SongID == songID && diff.Status == models.DiffRejected {
+			rejected = append(rejected, diff)
+		}
+	}
+	return rejected, nil
+}
+
+func (s *memoryStore) StartRun(_ context.Context, trigger string) (*models.SyncRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs = append(s.runs, models.SyncRun{ID: int64(len(s.runs) + 1), Trigger: trigger, StartedAt: time.Now()})
+	run := s.runs[len(s.runs)-1]
+	return &run, nil
+}
+
+func (s *memoryStore) RecordSongSync(_ context.Context, songID int, diffs []models.SongDiff) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recordErr != nil {
+		return s.recordErr
+	}
+	s.synced[songID] = true
+	s.diffs = append(s.diffs, diffs...)
+	return nil
+}
+
+func (s *memoryStore) FinishRun(_ context.Context, run *models.SyncRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	run.FinishedAt = &now
+	s.runs[run.ID-1] = *run
+	if s.finished != nil {
+		close(s.finished)
+	}
+	return nil
+}
+
+// catalogue serves the upstream details of songs by title.
+type catalogue map[string]models.SongDetail
+
+func (c catalogue) Name() string {
+	return "catalogue"
+}
+
+func (c catalogue) GetSongDetail(_ context.Context, songTitle, _ string) (*models.SongDetail, *rpc.HttpError) {
+	detail, ok := c[songTitle]
+	if !ok {
+		return nil, &rpc.HttpError{StatusCode: http.StatusNotFound, Status: "Song Not Found"}
+	}
+	return &detail, nil
+}
+
+func song(id int, title, date, link, lyrics string) models.Song {
+	releaseDate, err := models.ParseReleaseDate(date)
+	if err != nil {
+		panic(err)
+	}
+	return models.Song{
+		SongInfo: models.SongInfo{ID: id, Title: title, Group: "Group", ReleaseDate: releaseDate, Link: link},
+		Lyrics:   lyrics,
+	}
+}
+
+func newFixture() (*memoryStore, catalogue) {
+	store := &memoryStore{
+		songs: []models.Song{
+			song(1, "Same", "16.07.2006", "https://a", "Verse"),
+			song(2, "Changed", "2006", "https://old", "Verse 1"),
+			song(3, "Missing", "2006", "https://b", "Verse"),
+		},
+		synced: make(map[int]bool),
+	}
+	details := catalogue{
+		"Same":    {ReleaseDate: "2006-07-16", Link: "https://a", Text: "Verse"},
+		"Changed": {ReleaseDate: "16.07.2006", Link: "https://new", Text: "Verse 1\n\nVerse 2"},
+	}
+	return store, details
+}
+
+func TestSyncer_RecordsDifferences(t *testing.T) {
+	store, details := newFixture()
+	syncer := resync.NewSyncer(store, details, resync.WithAutoApply(models.SyncFieldLink))
+
+	run, err := syncer.RunOnce(context.Background(), "scheduled")
+	require.NoError(t, err)
+	require.Equal(t, 3, run.Checked)
+	require.Equal(t, 1, run.Changed)
+	require.Equal(t, 1, run.Failed)
+	require.NotNil(t, run.FinishedAt)
+
+	require.Len(t, store.diffs, 3)
+	for _, diff := range store.diffs {
+		require.Equal(t, 2, diff.SongID)
+		require.Equal(t, run.ID, diff.RunID)
+	}
+	require.Equal(t, models.SongDiff{
+		RunID: run.ID, SongID: 2, Field: models.SyncFieldReleaseDate,
+		OldValue: "2006", NewValue: "16.07.2006", Status: models.DiffPending,
+	}, store.diffs[0])
+	require.Equal(t, models.SyncFieldLink, store.diffs[1].Field)
+	require.Equal(t, models.DiffApplied, store.diffs[1].Status)
+	require.Equal(t, models.SyncFieldLyrics, store.diffs[2].Field)
+	require.Equal(t, models.DiffPending, store.diffs[2].Status)
+
+	// Failed songs are left stale, so that they are checked again.
+	require.Len(t, store.synced, 2)
+	require.False(t, store.synced[3])
+	run, err = syncer.RunOnce(context.Background(), "scheduled")
+	require.NoError(t, err)
+	require.Equal(t, 1, run.Checked)
+	require.Equal(t, 1, run.Failed)
+}
+
+func TestSyncer_FinishesAfterFailedRecord(t *testing.T) {
+	store, details := newFixture()
+	store.recordErr = errors.New("database unavailable")
+	syncer := resync.NewSyncer(store, details)
+
+	run, err := syncer.RunOnce(context.Background(), "scheduled")
+	require.NoError(t, err)
+	require.Equal(t, 3, run.Checked)
+	require.Zero(t, run.Changed)
+	require.Equal(t, 3, run.Failed)
+	require.NotNil(t, run.FinishedAt)
+	require.NotNil(t, store.runs[0].FinishedAt, "The run is finished in the store")
+}
+
+func TestSyncer_SkipsRejectedDifferences(t *testing.T) {
+	store, details := newFixture()
+	store.diffs = []models.SongDiff{
+		{SongID: 2, Field: models.SyncFieldLink, OldValue: "https://old", NewValue: "https://new", Status: models.DiffRejected},
+		{SongID: 2, Field: models.SyncFieldLyrics, OldValue: "Verse 1", NewValue: "Verse 1\n\nVerse 3", Status: models.DiffRejected},
+	}
+	syncer := resync.NewSyncer(store, details)
+
+	run, err := syncer.RunOnce(context.Background(), "scheduled")
+	require.NoError(t, err)
+	require.Equal(t, 1, run.Changed)
+	require.Len(t, store.diffs, 4, "Only the link was rejected with the same value")
+	require.Equal(t, models.SyncFieldReleaseDate, store.diffs[2].Field)
+	require.Equal(t, models.SyncFieldLyrics, store.diffs[3].Field)
+	require.Equal(t, "Verse 1\n\nVerse 2", store.diffs[3].NewValue)
+}
+
+func TestSyncer_BatchSize(t *testing.T) {
+	store, details := newFixture()
+	syncer := resync.NewSyncer(store, details, resync.WithBatchSize(2))
+
+	run, err := syncer.RunOnce(context.Background(), "scheduled")
+	require.NoError(t, err)
+	require.Equal(t, 2, run.Checked)
+}
+
+func TestSyncer_Trigger(t *testing.T) {
+	store, details := newFixture()
+	store.finished = make(chan struct{})
+	syncer := resync.NewSyncer(store, details, resync.WithInterval(time.Hour))
+
+	_, err := syncer.Trigger(context.Background())
+	require.ErrorIs(t, err, resync.ErrRunning, "not running yet")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go syncer.Run(ctx)
+
+	var run *models.SyncRun
+	require.Eventually(t, func() bool {
+		run, err = syncer.Trigger(context.Background())
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, "manual", run.Trigger)
+
+	<-store.finished
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Equal(t, 3, store.runs[0].Checked)
+}
@@ -0,0 +1,70 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"github.com/yankokirill/song-library/test/mock"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	server := mock.NewExternalApiServer()
+	defer server.Close()
+
+	recorder, err := rpc.NewRecordingTransport(http.DefaultTransport, dir)
+	require.NoError(t, err)
+	live := rpc.NewHTTPProvider(server.URL, rpc.WithTransport(recorder))
+
+	recorded, Err := live.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	_, Err = live.GetSongDetail(context.Background(), "Unknown", "Muse")
+	require.Equal(t, http.StatusNotFound, Err.StatusCode)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	replay, err := rpc.NewReplayTransport(dir)
+	require.NoError(t, err)
+	offline := rpc.NewHTTPProvider("http://external-api.invalid", rpc.WithTransport(replay))
+
+	replayed, Err := offline.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	require.Equal(t, recorded, replayed)
+	_, Err = offline.GetSongDetail(context.Background(), "Unknown", "Muse")
+	require.Equal(t, http.StatusNotFound, Err.StatusCode)
+	require.Empty(t, replay.Missed())
+}
+
+func TestReplay_FailsOnUnrecordedRequests(t *testing.T) {
+	dir := t.TempDir()
+	server := mock.NewExternalApiServer()
+	defer server.Close()
+
+	recorder, err := rpc.NewRecordingTransport(http.DefaultTransport, dir)
+	require.NoError(t, err)
+	_, Err := rpc.NewHTTPProvider(server.URL, rpc.WithTransport(recorder)).
+		GetSongDetail(context.Background(), "Yellow", "Coldplay")
+	require.Nil(t, Err)
+
+	replay, err := rpc.NewReplayTransport(dir)
+	require.NoError(t, err)
+	offline := rpc.NewHTTPProvider("http://external-api.invalid", rpc.WithTransport(replay), rpc.WithMaxAttempts(3))
+
+	_, Err = offline.GetSongDetail(context.Background(), "Yellow", "Muse")
+	require.NotNil(t, Err)
+	require.True(t, errors.Is(Err.LogErr, rpc.ErrNotRecorded))
+	require.Equal(t, http.StatusInternalServerError, Err.StatusCode)
+	require.Equal(t, rpc.CauseNotRecorded, Err.Cause)
+	require.Contains(t, Err.Status, "GET /info?group=Muse&song=Yellow", "the missing fixture is named")
+	require.Equal(t, []string{"GET /info?group=Muse&song=Yellow"}, replay.Missed())
+	require.Equal(t, 1, replay.Requests(), "unrecorded requests are not retried")
+
+	_, err = rpc.NewReplayTransport(t.TempDir())
+	require.Error(t, err, "an empty fixture directory is a mistake")
+}
@@ -0,0 +1,113 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"github.com/yankokirill/song-library/test/mock"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type lookupKey struct {
+	title string
+	group string
+}
+
+// memoryLookupCache mimics the expiry of the Postgres lookup cache.
+type memoryLookupCache struct {
+	lookups map[lookupKey]models.SongLookup
+	failing bool
+}
+
+func newLookupCache() *memoryLookupCache {
+	return &memoryLookupCache{lookups: make(map[lookupKey]models.SongLookup)}
+}
+
+func (c *memoryLookupCache) GetLookup(_ context.Context, title, group string) (*models.SongLookup, error) {
+	if c.failing {
+		return nil, errors.New("cache is down")
+	}
+	lookup, ok := c.lookups[lookupKey{title, group}]
+	if !ok || !lookup.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	return &lookup, nil
+}
+
+func (c *memoryLookupCache) PutLookup(_ context.Context, lookup *models.SongLookup) error {
+	if c.failing {
+		return errors.New("cache is down")
+	}
+	c.lookups[lookupKey{lookup.Title, lookup.Group}] = *lookup
+	return nil
+}
+
+func newCachingProvider(t *testing.T, cache rpc.LookupCache, ttl, negativeTTL time.Duration) (*rpc.CachingProvider, *mock.Faults) {
+	t.Helper()
+	faults := &mock.Faults{}
+	server := mock.NewFaultyExternalApiServer(faults)
+	t.Cleanup(server.Close)
+	return rpc.NewCachingProvider(rpc.NewHTTPProvider(server.URL), cache, ttl, negativeTTL), faults
+}
+
+func TestCachingProvider_CachesHits(t *testing.T) {
+	cache := newLookupCache()
+	provider, faults := newCachingProvider(t, cache, time.Hour, time.Hour)
+	require.Equal(t, "external-api", provider.Name())
+
+	for _, title := range []string{"Supermassive Black Hole", "supermassive  black hole ", "SUPERMASSIVE BLACK HOLE"} {
+		detail, Err := provider.GetSongDetail(context.Background(), title, "Muse")
+		require.Nil(t, Err)
+		require.Equal(t, "16.07.2006", detail.ReleaseDate)
+		require.Equal(t, "external-api", detail.Source)
+	}
+	require.Equal(t, 1, faults.Requests())
+	require.Contains(t, cache.lookups, lookupKey{"supermassive black hole", "muse"})
+}
+
+func TestCachingProvider_CachesNotFound(t *testing.T) {
+	cache := newLookupCache()
+	provider, faults := newCachingProvider(t, cache, time.Hour, 50*time.Millisecond)
+
+	for range 2 {
+		_, Err := provider.GetSongDetail(context.Background(), "Unknown", "Muse")
+		require.NotNil(t, Err)
+		require.Equal(t, http.StatusNotFound, Err.StatusCode)
+	}
+	require.Equal(t, 1, faults.Requests())
+
+	time.Sleep(60 * time.Millisecond)
+	_, Err := provider.GetSongDetail(context.Background(), "Unknown", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, 2, faults.Requests())
+}
+
+func TestCachingProvider_DoesNotCacheFailures(t *testing.T) {
+	cache := newLookupCache()
+	provider, faults := newCachingProvider(t, cache, time.Hour, time.Hour)
+	faults.FailNext(1, http.StatusServiceUnavailable)
+
+	_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Empty(t, cache.lookups)
+
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	require.Equal(t, 2, faults.Requests())
+}
+
+func TestCachingProvider_FailingCache(t *testing.T) {
+	cache := newLookupCache()
+	cache.failing = true
+	provider, faults := newCachingProvider(t, cache, time.Hour, time.Hour)
+
+	for range 2 {
+		_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+		require.Nil(t, Err)
+	}
+	require.Equal(t, 2, faults.Requests())
+}
@@ -0,0 +1,96 @@
+package rpc_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/logging"
+	"github.com/yankokirill/song-library/internal/mockapi"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"github.com/yankokirill/song-library/test/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	server := mock.NewExternalApiServer()
+	defer server.Close()
+	provider := rpc.NewHTTPProvider(server.URL)
+
+	detail, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	require.Equal(t, "16.07.2006", detail.ReleaseDate)
+	require.Equal(t, "external-api", detail.Source)
+
+	_, Err = provider.GetSongDetail(context.Background(), "Clocks", "Coldplay")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusNotFound, Err.StatusCode)
+}
+
+func TestHTTPProvider_PropagatesRequestID(t *testing.T) {
+	songs, err := mockapi.LoadSongs(mockapi.DefaultSongs)
+	require.NoError(t, err)
+	upstream := mockapi.NewHandler(songs, &mockapi.Faults{})
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(logging.RequestIDHeader)
+		upstream.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	provider := rpc.NewHTTPProvider(server.URL)
+
+	ctx := logging.WithRequestID(context.Background(), "host/abc-000042")
+	_, Err := provider.GetSongDetail(ctx, "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	require.Equal(t, "host/abc-000042", received)
+}
+
+func TestFileProvider(t *testing.T) {
+	provider, err := rpc.NewFileProvider("testdata/catalogue.json")
+	require.NoError(t, err)
+
+	detail, Err := provider.GetSongDetail(context.Background(), "Clocks", "Coldplay")
+	require.Nil(t, Err)
+	require.Equal(t, "24.03.2003", detail.ReleaseDate)
+	require.Equal(t, "https://www.youtube.com/watch?v=d020hcWA_Wg", detail.Link)
+	require.Equal(t, "file", detail.Source)
+
+	_, Err = provider.GetSongDetail(context.Background(), "Clocks", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusNotFound, Err.StatusCode)
+
+	_, err = rpc.NewFileProvider("testdata/missing.json")
+	require.Error(t, err)
+}
+
+func TestChainProvider(t *testing.T) {
+	server := mock.NewExternalApiServer()
+	defer server.Close()
+	file, err := rpc.NewFileProvider("testdata/catalogue.json")
+	require.NoError(t, err)
+	chain := rpc.NewChainProvider(rpc.NewHTTPProvider(server.URL), file)
+	require.Equal(t, "chain(external-api,file)", chain.Name())
+
+	detail, Err := chain.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	require.Equal(t, "external-api", detail.Source)
+
+	detail, Err = chain.GetSongDetail(context.Background(), "Clocks", "Coldplay")
+	require.Nil(t, Err)
+	require.Equal(t, "file", detail.Source)
+
+	_, Err = chain.GetSongDetail(context.Background(), "Unknown", "Unknown")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusNotFound, Err.StatusCode)
+}
+
+func TestChainProvider_FallsBackOnFailure(t *testing.T) {
+	file, err := rpc.NewFileProvider("testdata/catalogue.json")
+	require.NoError(t, err)
+	chain := rpc.NewChainProvider(rpc.NewHTTPProvider("http://127.0.0.1:1"), file)
+
+	detail, Err := chain.GetSongDetail(context.Background(), "Clocks", "Coldplay")
+	require.Nil(t, Err)
+	require.Equal(t, "file", detail.Source)
+}
@@ -0,0 +1,81 @@
+package rpc_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/ratelimit"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPProvider_RateLimit(t *testing.T) {
+	bucket := ratelimit.NewBucket(20, 1)
+	provider, faults := newFaultyProvider(t, rpc.WithRateLimit(bucket, 100*time.Millisecond))
+
+	start := time.Now()
+	for range 3 {
+		_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+		require.Nil(t, Err)
+	}
+	require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	require.Equal(t, 3, faults.Requests())
+
+	// The next token is a second away, longer than a caller may wait.
+	bucket = ratelimit.NewBucket(1, 1)
+	provider, faults = newFaultyProvider(t, rpc.WithRateLimit(bucket, 100*time.Millisecond))
+	_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusServiceUnavailable, Err.StatusCode)
+	require.InDelta(t, time.Second, Err.RetryAfter, float64(50*time.Millisecond))
+	require.Equal(t, 1, faults.Requests())
+}
+
+func TestHTTPProvider_MaxConcurrency(t *testing.T) {
+	provider, faults := newFaultyProvider(t, rpc.WithMaxConcurrency(2))
+	faults.SetDelay(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+			require.Nil(t, Err)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 6, faults.Requests())
+	require.Equal(t, 2, faults.MaxInFlight())
+}
+
+func TestHTTPProvider_HonoursRetryAfter(t *testing.T) {
+	breaker := rpc.NewCircuitBreaker(1, time.Minute)
+	provider, faults := newFaultyProvider(t, rpc.WithMaxAttempts(3), rpc.WithCircuitBreaker(breaker))
+	faults.FailNext(1, http.StatusTooManyRequests)
+	faults.SetRetryAfter("1")
+
+	_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusServiceUnavailable, Err.StatusCode)
+	require.Equal(t, time.Second, Err.RetryAfter)
+	require.Equal(t, 1, faults.Requests(), "429 is not retried")
+	require.Equal(t, rpc.BreakerClosed, breaker.Status().State, "429 is not an outage")
+
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusServiceUnavailable, Err.StatusCode)
+	require.Greater(t, Err.RetryAfter, time.Duration(0))
+	require.LessOrEqual(t, Err.RetryAfter, time.Second)
+	require.Equal(t, 1, faults.Requests(), "no requests while blocked")
+
+	time.Sleep(Err.RetryAfter)
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	require.Equal(t, 2, faults.Requests())
+}
@@ -0,0 +1,221 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/mockapi"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"github.com/yankokirill/song-library/test/mock"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newFaultyProvider(t *testing.T, opts ...rpc.HTTPProviderOption) (*rpc.HTTPProvider, *mock.Faults) {
+	t.Helper()
+	faults := &mock.Faults{}
+	server := mock.NewFaultyExternalApiServer(faults)
+	t.Cleanup(server.Close)
+
+	opts = append([]rpc.HTTPProviderOption{rpc.WithBackoff(time.Millisecond, 5*time.Millisecond)}, opts...)
+	return rpc.NewHTTPProvider(server.URL, opts...), faults
+}
+
+func TestHTTPProvider_RetriesUnavailable(t *testing.T) {
+	provider, faults := newFaultyProvider(t, rpc.WithMaxAttempts(3))
+	faults.FailNext(2, http.StatusServiceUnavailable)
+
+	detail, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	require.Equal(t, "16.07.2006", detail.ReleaseDate)
+	require.Equal(t, 3, faults.Requests())
+}
+
+func TestHTTPProvider_GivesUpAfterMaxAttempts(t *testing.T) {
+	provider, faults := newFaultyProvider(t, rpc.WithMaxAttempts(3))
+	faults.FailNext(5, http.StatusBadGateway)
+
+	_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusBadGateway, Err.StatusCode)
+	require.Equal(t, 3, faults.Requests())
+}
+
+func TestHTTPProvider_RetriesTimeouts(t *testing.T) {
+	provider, faults := newFaultyProvider(t, rpc.WithMaxAttempts(2), rpc.WithTimeout(20*time.Millisecond))
+	faults.SetDelay(50 * time.Millisecond)
+
+	_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, 2, faults.Requests())
+
+	faults.SetDelay(0)
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+}
+
+func TestHTTPProvider_DoesNotRetryPermanentErrors(t *testing.T) {
+	provider, faults := newFaultyProvider(t, rpc.WithMaxAttempts(3))
+
+	_, Err := provider.GetSongDetail(context.Background(), "Unknown", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusNotFound, Err.StatusCode)
+
+	faults.FailNext(1, http.StatusInternalServerError)
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusBadGateway, Err.StatusCode)
+	require.Equal(t, 2, faults.Requests())
+}
+
+func TestHTTPProvider_MapsUpstreamErrors(t *testing.T) {
+	provider, faults := newFaultyProvider(t, rpc.WithTimeout(20*time.Millisecond))
+
+	_, Err := provider.GetSongDetail(context.Background(), "Unknown", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusNotFound, Err.StatusCode)
+	require.Equal(t, rpc.CauseSongNotFound, Err.Cause)
+
+	_, Err = provider.GetSongDetail(context.Background(), "Faint", "Linkin Park")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusBadGateway, Err.StatusCode, "upstream 500 is not ours")
+	require.Equal(t, rpc.CauseUpstreamError, Err.Cause)
+
+	faults.FailNext(1, http.StatusGatewayTimeout)
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusGatewayTimeout, Err.StatusCode)
+	require.Equal(t, rpc.CauseUpstreamTimeout, Err.Cause)
+
+	faults.SetDelay(50 * time.Millisecond)
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	faults.SetDelay(0)
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusGatewayTimeout, Err.StatusCode)
+	require.Equal(t, rpc.CauseUpstreamTimeout, Err.Cause)
+}
+
+func TestHTTPProvider_ValidatesResponses(t *testing.T) {
+	provider, faults := newFaultyProvider(t)
+
+	_, Err := provider.GetSongDetail(context.Background(), "Broken", "Broken")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusBadGateway, Err.StatusCode)
+	require.Equal(t, rpc.CauseInvalidResponse, Err.Cause)
+
+	faults.SetSongFault(mockapi.SongFault{
+		SongInfo:  mockapi.SongInfo{Title: "Supermassive Black Hole", Group: "Muse"},
+		Malformed: true,
+	})
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusBadGateway, Err.StatusCode)
+	require.Equal(t, rpc.CauseInvalidResponse, Err.Cause)
+}
+
+func TestHTTPProvider_CircuitBreaker(t *testing.T) {
+	breaker := rpc.NewCircuitBreaker(3, 50*time.Millisecond)
+	provider, faults := newFaultyProvider(t, rpc.WithMaxAttempts(1), rpc.WithCircuitBreaker(breaker))
+	faults.FailNext(4, http.StatusServiceUnavailable)
+
+	for range 3 {
+		_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+		require.NotNil(t, Err)
+	}
+	require.Equal(t, rpc.BreakerOpen, breaker.Status().State)
+	require.Equal(t, 3, breaker.Status().ConsecutiveFailures)
+
+	_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, http.StatusServiceUnavailable, Err.StatusCode)
+	require.True(t, errors.Is(Err.LogErr, rpc.ErrCircuitOpen))
+	require.Equal(t, 3, faults.Requests(), "open breaker must not reach the upstream")
+
+	time.Sleep(60 * time.Millisecond)
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err, "failed probe")
+	require.Equal(t, rpc.BreakerOpen, breaker.Status().State)
+
+	time.Sleep(60 * time.Millisecond)
+	_, Err = provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	require.Equal(t, rpc.BreakerClosed, breaker.Status().State)
+	require.Zero(t, breaker.Status().ConsecutiveFailures)
+	require.Equal(t, 5, faults.Requests())
+}
+
+func TestHTTPProvider_CircuitBreakerIgnoresCallerFaults(t *testing.T) {
+	breaker := rpc.NewCircuitBreaker(1, time.Minute)
+	provider, faults := newFaultyProvider(t, rpc.WithMaxAttempts(1), rpc.WithCircuitBreaker(breaker))
+
+	faults.FailNext(1, http.StatusTooManyRequests)
+	_, Err := provider.GetSongDetail(context.Background(), "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, rpc.BreakerClosed, breaker.Status().State, "a rate limited call is not a failure")
+
+	provider, faults = newFaultyProvider(t, rpc.WithMaxAttempts(1), rpc.WithCircuitBreaker(breaker))
+	faults.SetDelay(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, Err = provider.GetSongDetail(ctx, "Supermassive Black Hole", "Muse")
+	require.NotNil(t, Err)
+	require.Equal(t, rpc.BreakerClosed, breaker.Status().State, "a call cancelled by the caller is not a failure")
+	require.Zero(t, breaker.Status().ConsecutiveFailures)
+}
+
+// allow fails the test unless the breaker lets a call through.
+func allow(t *testing.T, breaker *rpc.CircuitBreaker) rpc.BreakerToken {
+	t.Helper()
+	token, allowed := breaker.Allow()
+	require.True(t, allowed)
+	return token
+}
+
+func TestCircuitBreaker_Release(t *testing.T) {
+	breaker := rpc.NewCircuitBreaker(1, 10*time.Millisecond)
+	breaker.Record(allow(t, breaker), true)
+
+	time.Sleep(20 * time.Millisecond)
+	breaker.Release(allow(t, breaker))
+	require.Equal(t, rpc.BreakerHalfOpen, breaker.Status().State)
+	allow(t, breaker)
+}
+
+func TestCircuitBreaker_SingleProbe(t *testing.T) {
+	breaker := rpc.NewCircuitBreaker(1, 10*time.Millisecond)
+	breaker.Record(allow(t, breaker), true)
+	_, allowed := breaker.Allow()
+	require.False(t, allowed)
+
+	time.Sleep(20 * time.Millisecond)
+	probe := allow(t, breaker)
+	require.Equal(t, rpc.BreakerHalfOpen, breaker.Status().State)
+	_, allowed = breaker.Allow()
+	require.False(t, allowed, "only one probe at a time")
+	breaker.Record(probe, false)
+	allow(t, breaker)
+}
+
+func TestCircuitBreaker_LateResults(t *testing.T) {
+	breaker := rpc.NewCircuitBreaker(2, 10*time.Millisecond)
+	late := []rpc.BreakerToken{allow(t, breaker), allow(t, breaker), allow(t, breaker), allow(t, breaker)}
+	breaker.Record(late[0], true)
+	require.Equal(t, rpc.BreakerClosed, breaker.Status().State)
+	require.Equal(t, 1, breaker.Status().ConsecutiveFailures, "failures of calls are counted while closed")
+	breaker.Record(late[1], true)
+	require.Equal(t, rpc.BreakerOpen, breaker.Status().State)
+
+	time.Sleep(20 * time.Millisecond)
+	probe := allow(t, breaker)
+	breaker.Record(late[2], false)
+	require.Equal(t, rpc.BreakerHalfOpen, breaker.Status().State, "a late success does not close the breaker")
+	breaker.Release(late[3])
+	_, allowed := breaker.Allow()
+	require.False(t, allowed, "a late release does not end the probe")
+
+	breaker.Record(probe, true)
+	require.Equal(t, rpc.BreakerOpen, breaker.Status().State)
+	breaker.Record(late[3], true)
+	require.Equal(t, 3, breaker.Status().ConsecutiveFailures, "late failures are ignored until the breaker closes")
+}
@@ -0,0 +1,146 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	delivery "github.com/yankokirill/song-library/internal/delivery/http"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/repository/postgres"
+	"github.com/yankokirill/song-library/internal/rpc"
+	"github.com/yankokirill/song-library/internal/tracing"
+	"github.com/yankokirill/song-library/test/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// record installs a tracer provider keeping the spans in memory.
+func record(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		require.NoError(t, provider.Shutdown(context.Background()))
+	})
+	return exporter
+}
+
+func findSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "No span named %q", name)
+	return tracetest.SpanStub{}
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+type songRepo struct {
+	postgres.SongRepository
+}
+
+func (songRepo) GetSongsInfo(context.Context, *models.PaginationInfo) ([]models.SongInfo, error) {
+	return nil, nil
+}
+
+func TestServer_TracesRequests(t *testing.T) {
+	exporter := record(t)
+	server := httptest.NewServer(delivery.NewServer(songRepo{}, nil, "").Routes())
+	defer server.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "caller")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/library/songs", nil)
+	require.NoError(t, err)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.End()
+
+	span := findSpan(t, exporter, "GET /library/songs")
+	require.Equal(t, trace.SpanKindServer, span.SpanKind)
+	require.Equal(t, parent.SpanContext().TraceID(), span.SpanContext.TraceID(), "The trace of the caller is continued")
+	require.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+	require.Equal(t, "/library/songs", attr(span, "http.route").AsString())
+	require.EqualValues(t, http.StatusOK, attr(span, "http.response.status_code").AsInt64())
+}
+
+func TestHTTPProvider_TracesCalls(t *testing.T) {
+	exporter := record(t)
+	var traceparent string
+	upstream := mock.NewExternalApiServer()
+	defer upstream.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		upstream.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	provider := rpc.NewHTTPProvider(server.URL)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "add song")
+	_, Err := provider.GetSongDetail(ctx, "Supermassive Black Hole", "Muse")
+	require.Nil(t, Err)
+	parent.End()
+
+	span := findSpan(t, exporter, "GET /info")
+	require.Equal(t, trace.SpanKindClient, span.SpanKind)
+	require.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+	require.Equal(t, "ok", attr(span, "song_library.outcome").AsString())
+	require.Contains(t, traceparent, span.SpanContext.SpanID().String(), "The trace context is passed to the API")
+
+	_, Err = provider.GetSongDetail(context.Background(), "Clocks", "Coldplay")
+	require.NotNil(t, Err)
+	spans := exporter.GetSpans()
+	notFound := spans[len(spans)-1]
+	require.Equal(t, rpc.CauseSongNotFound, attr(notFound, "song_library.outcome").AsString())
+	require.Equal(t, codes.Unset, notFound.Status.Code, "An unknown song is not a failure of the API")
+}
+
+func TestQueryTracer(t *testing.T) {
+	exporter := record(t)
+	tracer := tracing.QueryTracer{}
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "select * from get_songs_info($1)"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 3")})
+	ctx = tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "\n\tINSERT INTO songs VALUES ($1)"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("duplicate key")})
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "SELECT", spans[0].Name)
+	require.Equal(t, "postgresql", attr(spans[0], "db.system").AsString())
+	require.Equal(t, "select * from get_songs_info($1)", attr(spans[0], "db.query.text").AsString())
+	require.EqualValues(t, 3, attr(spans[0], "db.rows_affected").AsInt64())
+	require.Equal(t, "INSERT", spans[1].Name)
+	require.Equal(t, codes.Error, spans[1].Status.Code)
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := tracing.Setup(context.Background(), "none")
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	_, err = tracing.Setup(context.Background(), "zipkin")
+	require.Error(t, err)
+}
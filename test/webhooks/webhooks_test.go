@@ -0,0 +1,208 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"github.com/yankokirill/song-library/internal/models"
+	"github.com/yankokirill/song-library/internal/webhooks"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	webhooks   []models.Webhook
+	deliveries []*models.WebhookDelivery
+}
+
+func (s *memoryStore) EnqueueDeliveries(_ context.Context, event models.Event, body []byte) error {
+	for _, webhook := range s.webhooks {
+		if !slices.Contains(webhook.Events, event.Type) {
+			continue
+		}
+		duplicate := slices.ContainsFunc(s.deliveries, func(d *models.WebhookDelivery) bool {
+			return d.WebhookID == webhook.ID && d.EventID == event.ID
+		})
+		if duplicate {
+			continue
+		}
+		s.deliveries = append(s.deliveries, &models.WebhookDelivery{
+			ID:        int64(len(s.deliveries) + 1),
+			WebhookID: webhook.ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Status:    models.DeliveryPending,
+			Payload:   body,
+			URL:       webhook.URL,
+			Secret:    webhook.Secret,
+		})
+	}
+	return nil
+}
+
+func (s *memoryStore) ClaimDueDeliveries(_ context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	var due []models.WebhookDelivery
+	for _, d := range s.deliveries {
+		if len(due) < limit && d.Status == models.DeliveryPending && !d.NextAttemptAt.After(time.Now()) {
+			d.NextAttemptAt = time.Now().Add(lease)
+			due = append(due, *d)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) RecordAttempt(_ context.Context, attempt models.DeliveryAttempt) error {
+	for _, d := range s.deliveries {
+		if d.ID == attempt.DeliveryID {
+			d.Attempts++
+			d.Status = attempt.Status
+			d.LastStatusCode = attempt.StatusCode
+			d.LastError = attempt.Error
+			d.History = append(d.History, attempt)
+			if attempt.Status == models.DeliveryPending {
+				d.NextAttemptAt = attempt.NextAttemptAt
+			}
+		}
+	}
+	return nil
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	rc.received = append(rc.received, r)
+	rc.bodies = append(rc.bodies, body)
+	if rc.failures != 0 {
+		rc.failures--
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newEvent(id int64, eventType string) models.Event {
+	return models.Event{
+		ID:        id,
+		Type:      eventType,
+		SongID:    1,
+		Payload:   json.RawMessage(`{"id":1,"song":"Yellow","group":"Coldplay"}`),
+		CreatedAt: time.Now(),
+	}
+}
+
+func setup(t *testing.T, rc *receiver, events ...string) (*memoryStore, *webhooks.Sink) {
+	t.Helper()
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	store := &memoryStore{webhooks: []models.Webhook{{ID: 1, URL: server.URL, Events: events, Secret: "secret"}}}
+	return store, webhooks.NewSink(store)
+}
+
+func TestDelivery_Signed(t *testing.T) {
+	rc := &receiver{}
+	store, sink := setup(t, rc, models.EventSongCreated)
+
+	require.NoError(t, sink.Publish(context.Background(), newEvent(1, models.EventSongCreated)))
+	require.NoError(t, sink.Publish(context.Background(), newEvent(1, models.EventSongCreated)))
+	require.NoError(t, sink.Publish(context.Background(), newEvent(2, models.EventSongDeleted)))
+	require.Len(t, store.deliveries, 1, "Only new events of subscribed types are delivered")
+
+	deliverer := webhooks.NewDeliverer(store)
+	n, err := deliverer.DeliverDue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, models.DeliverySucceeded, store.deliveries[0].Status)
+
+	require.Len(t, rc.received, 1)
+	req, body := rc.received[0], rc.bodies[0]
+	require.Equal(t, models.EventSongCreated, req.Header.Get("X-Webhook-Event"))
+	require.Equal(t, "1", req.Header.Get("X-Webhook-Delivery"))
+
+	signature := req.Header.Get(webhooks.SignatureHeader)
+	require.NoError(t, webhooks.Verify("secret", signature, body, time.Minute))
+	require.ErrorIs(t, webhooks.Verify("other", signature, body, time.Minute), webhooks.InvalidSignature)
+	require.ErrorIs(t, webhooks.Verify("secret", signature, append(body, ' '), time.Minute), webhooks.InvalidSignature)
+
+	var event models.Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	require.Equal(t, int64(1), event.ID)
+	require.JSONEq(t, `{"id":1,"song":"Yellow","group":"Coldplay"}`, string(event.Payload))
+}
+
+func TestDelivery_RetriedWithBackoff(t *testing.T) {
+	rc := &receiver{failures: 2}
+	store, sink := setup(t, rc, models.EventSongUpdated)
+	require.NoError(t, sink.Publish(context.Background(), newEvent(1, models.EventSongUpdated)))
+
+	deliverer := webhooks.NewDeliverer(store, webhooks.WithBackoff(50*time.Millisecond, time.Second))
+	delivery := store.deliveries[0]
+
+	_, err := deliverer.DeliverDue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, models.DeliveryPending, delivery.Status)
+	require.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+	require.WithinDuration(t, time.Now().Add(50*time.Millisecond), delivery.NextAttemptAt, 40*time.Millisecond)
+
+	n, err := deliverer.DeliverDue(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n, "Delivery must wait for the backoff")
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = deliverer.DeliverDue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, delivery.Attempts)
+	require.WithinDuration(t, time.Now().Add(100*time.Millisecond), delivery.NextAttemptAt, 40*time.Millisecond)
+
+	time.Sleep(110 * time.Millisecond)
+	_, err = deliverer.DeliverDue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, models.DeliverySucceeded, delivery.Status)
+	require.Equal(t, 3, delivery.Attempts)
+	require.Len(t, rc.received, 3)
+
+	require.Len(t, delivery.History, 3, "Every attempt is kept")
+	for i, status := range []models.DeliveryStatus{models.DeliveryPending, models.DeliveryPending, models.DeliverySucceeded} {
+		require.Equal(t, status, delivery.History[i].Status)
+		require.False(t, delivery.History[i].AttemptedAt.IsZero())
+		require.GreaterOrEqual(t, delivery.History[i].DurationMS, 0.0)
+	}
+	require.Equal(t, http.StatusServiceUnavailable, delivery.History[0].StatusCode)
+	require.Equal(t, http.StatusNoContent, delivery.History[2].StatusCode)
+}
+
+func TestDelivery_DeadLetter(t *testing.T) {
+	rc := &receiver{failures: -1}
+	store, sink := setup(t, rc, models.EventSongDeleted)
+	require.NoError(t, sink.Publish(context.Background(), newEvent(1, models.EventSongDeleted)))
+
+	deliverer := webhooks.NewDeliverer(store,
+		webhooks.WithMaxAttempts(3),
+		webhooks.WithBackoff(time.Millisecond, time.Millisecond),
+	)
+	for range 5 {
+		_, err := deliverer.DeliverDue(context.Background())
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	delivery := store.deliveries[0]
+	require.Equal(t, models.DeliveryDead, delivery.Status)
+	require.Equal(t, 3, delivery.Attempts)
+	require.Len(t, rc.received, 3)
+	require.Contains(t, delivery.LastError, "503")
+}